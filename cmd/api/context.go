@@ -0,0 +1,56 @@
+// Filename: cmd/api/context.go
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"Quiz3.zioncastillo.net/internal/data"
+)
+
+// contextKey avoids collisions with keys set by other packages
+type contextKey string
+
+const (
+	userContextKey        = contextKey("user")
+	permissionsContextKey = contextKey("permissions")
+)
+
+// contextSetUser() returns a copy of the request with the user added to its context
+func (app *application) contextSetUser(r *http.Request, user *data.User) *http.Request {
+	ctx := context.WithValue(r.Context(), userContextKey, user)
+	return r.WithContext(ctx)
+}
+
+// contextGetUser() retrieves the user set by the authenticate middleware.
+// Every request passes through that middleware, so a missing user is a bug
+func (app *application) contextGetUser(r *http.Request) *data.User {
+	user, ok := r.Context().Value(userContextKey).(*data.User)
+	if !ok {
+		panic("missing user value in request context")
+	}
+	return user
+}
+
+// contextSetPermissions() returns a copy of the request with the user's
+// permissions added to its context
+func (app *application) contextSetPermissions(r *http.Request, permissions data.Permissions) *http.Request {
+	ctx := context.WithValue(r.Context(), permissionsContextKey, permissions)
+	return r.WithContext(ctx)
+}
+
+// contextGetPermissions() retrieves the permissions loaded by the
+// requirePermission middleware, or nil if it did not run
+func (app *application) contextGetPermissions(r *http.Request) data.Permissions {
+	permissions, _ := r.Context().Value(permissionsContextKey).(data.Permissions)
+	return permissions
+}
+
+// todoOwnerID() returns the owner id to scope todo queries by. Admins get 0,
+// which the TodoModel treats as "any owner"
+func (app *application) todoOwnerID(r *http.Request) int64 {
+	if app.contextGetPermissions(r).Include(data.PermissionTodosAdmin) {
+		return 0
+	}
+	return app.contextGetUser(r).ID
+}
@@ -1,11 +1,21 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 )
+
+// statusClientClosedRequest is the non-standard status nginx uses when the
+// client goes away before the response is ready
+const statusClientClosedRequest = 499
+
 func (app *application) logError(r *http.Request, err error){
-	app.logger.Println(err)
+	app.logger.PrintError(err, map[string]any{
+		"request_method": r.Method,
+		"request_url":    r.URL.String(),
+	})
 }
 //we want to send json-formatted error message
 func (app *application) errorResponse(w http.ResponseWriter, r *http.Request, status int, message interface{}) {
@@ -17,8 +27,17 @@ func (app *application) errorResponse(w http.ResponseWriter, r *http.Request, st
 		w.WriteHeader(http.StatusInternalServerError)
 	}
 }
-//server error response
+//server error response. Queries which ran out of time or whose client
+//went away are not server faults and get their own responses
 func (app *application) serverErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		app.databaseTimeoutResponse(w, r, err)
+		return
+	case errors.Is(err, context.Canceled):
+		app.clientClosedRequestResponse(w, r, err)
+		return
+	}
 	//we will log the error
 	app.logError(r, err)
 	//prepare a message with the error
@@ -48,4 +67,61 @@ func (app * application) failedValidationResponse (w http.ResponseWriter, r *htt
 func (app *application) editConflictResponse(w http.ResponseWriter, r *http.Request) {
 	message := "unable to update the record due to an edit conflict, please try again"
 	app.errorResponse(w, r, http.StatusConflict, message)
-}
\ No newline at end of file
+}
+func (app *application) defaultListResponse(w http.ResponseWriter, r *http.Request) {
+	message := "the default list cannot be deleted"
+	app.errorResponse(w, r, http.StatusConflict, message)
+}
+
+func (app *application) invalidCredentialsResponse(w http.ResponseWriter, r *http.Request) {
+	message := "invalid authentication credentials"
+	app.errorResponse(w, r, http.StatusUnauthorized, message)
+}
+
+func (app *application) invalidAuthenticationTokenResponse(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("WWW-Authenticate", "Bearer")
+	message := "invalid or missing authentication token"
+	app.errorResponse(w, r, http.StatusUnauthorized, message)
+}
+
+func (app *application) authenticationRequiredResponse(w http.ResponseWriter, r *http.Request) {
+	message := "you must be authenticated to access this resource"
+	app.errorResponse(w, r, http.StatusUnauthorized, message)
+}
+
+func (app *application) inactiveAccountResponse(w http.ResponseWriter, r *http.Request) {
+	message := "your user account must be activated to access this resource"
+	app.errorResponse(w, r, http.StatusForbidden, message)
+}
+
+func (app *application) notPermittedResponse(w http.ResponseWriter, r *http.Request) {
+	message := "your user account doesn't have the necessary permissions to access this resource"
+	app.errorResponse(w, r, http.StatusForbidden, message)
+}
+
+func (app *application) rateLimitExceededResponse(w http.ResponseWriter, r *http.Request) {
+	message := "rate limit exceeded"
+	app.errorResponse(w, r, http.StatusTooManyRequests, message)
+}
+
+func (app *application) databaseTimeoutResponse(w http.ResponseWriter, r *http.Request, err error) {
+	app.logger.PrintWarn("database query timed out", map[string]any{
+		"request_method": r.Method,
+		"request_url":    r.URL.String(),
+		"error":          err.Error(),
+	})
+	message := "the server took too long to process the request, please try again"
+	app.errorResponse(w, r, http.StatusServiceUnavailable, message)
+}
+
+// clientClosedRequestResponse() records a request abandoned by its client.
+// The client will most likely never read the response
+func (app *application) clientClosedRequestResponse(w http.ResponseWriter, r *http.Request, err error) {
+	app.logger.PrintInfo("client closed request", map[string]any{
+		"request_method": r.Method,
+		"request_url":    r.URL.String(),
+		"error":          err.Error(),
+	})
+	message := "the client closed the request before the server could respond"
+	app.errorResponse(w, r, statusClientClosedRequest, message)
+}
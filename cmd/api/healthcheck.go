@@ -3,15 +3,26 @@
 package main
 
 import (
+	"context"
+	"database/sql"
 	"net/http"
+	"runtime/debug"
+	"time"
 )
 
+// How long the readiness check waits for PostgreSQL to answer
+const healthcheckDBTimeout = 2 * time.Second
+
 func (app *application) healthcheckHandler(w http.ResponseWriter, r *http.Request){
 
 	// Create a map to hold the healthcheck data
-	
+	status, code := "available", http.StatusOK
+	if err := app.pingDB(r.Context()); err != nil {
+		status, code = "degraded", http.StatusServiceUnavailable
+	}
+
 	data := envelope{
-		"Status": "available",
+		"Status": status,
 		"System_Information": map[string]string{
 			"Enviornment": app.config.env,
 		"Version": version,
@@ -19,10 +30,112 @@ func (app *application) healthcheckHandler(w http.ResponseWriter, r *http.Reques
 		
 	}
 	
-	err := app.writeJSON(w, http.StatusOK, data, nil)
+	err := app.writeJSON(w, code, data, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
 
-}
\ No newline at end of file
+}
+
+// liveHealthcheckHandler for the "GET /v1/healthcheck/live" endpoint. It
+// only shows that the process is up and serving requests
+func (app *application) liveHealthcheckHandler(w http.ResponseWriter, r *http.Request) {
+	data := envelope{
+		"status": "alive",
+		"uptime": time.Since(app.startedAt).Round(time.Second).String(),
+	}
+	err := app.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// readyHealthcheckHandler for the "GET /v1/healthcheck/ready" endpoint. It
+// responds 503 when the database cannot be reached so that a load balancer
+// stops sending traffic to this instance. The endpoint is public, so the
+// reason is only logged. The full pool statistics and build details are
+// published on the admin server
+func (app *application) readyHealthcheckHandler(w http.ResponseWriter, r *http.Request) {
+	status, code := "available", http.StatusOK
+
+	database := map[string]interface{}{"status": "up"}
+	if err := app.pingDB(r.Context()); err != nil {
+		app.logError(r, err)
+		status, code = "degraded", http.StatusServiceUnavailable
+		database["status"] = "unavailable"
+	} else {
+		database["migration"] = app.migrationVersion(r.Context())
+	}
+	stats := app.db.Stats()
+	database["pool"] = map[string]interface{}{
+		"open_connections": stats.OpenConnections,
+		"in_use":           stats.InUse,
+		"idle":             stats.Idle,
+		"wait_count":       stats.WaitCount,
+	}
+	build := buildInfo()
+
+	data := envelope{
+		"status":      status,
+		"environment": app.config.env,
+		"version":     version,
+		"uptime":      time.Since(app.startedAt).Round(time.Second).String(),
+		"build":       map[string]string{"version": build["version"], "revision": build["revision"]},
+		"database":    database,
+	}
+	err := app.writeJSON(w, code, data, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// pingDB() checks that PostgreSQL answers within healthcheckDBTimeout
+func (app *application) pingDB(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, healthcheckDBTimeout)
+	defer cancel()
+	return app.db.PingContext(ctx)
+}
+
+// migrationVersion() reports the newest schema version applied by
+// "api migrate", or "unknown" if there is no record. A database which is
+// not migrated by us would otherwise log on every probe, so the failure is
+// only logged the first time
+func (app *application) migrationVersion(ctx context.Context) map[string]interface{} {
+	ctx, cancel := context.WithTimeout(ctx, healthcheckDBTimeout)
+	defer cancel()
+
+	var version sql.NullInt64
+	err := app.db.QueryRowContext(ctx, `SELECT MAX(version) FROM schema_migrations`).Scan(&version)
+	if err != nil || !version.Valid {
+		if err != nil {
+			app.migrationWarning.Do(func() {
+				app.logger.PrintWarn("unable to read migration version", map[string]any{"error": err.Error()})
+			})
+		}
+		return map[string]interface{}{"version": "unknown"}
+	}
+	return map[string]interface{}{"version": version.Int64}
+}
+
+// buildInfo() describes the binary using the information embedded by the
+// Go toolchain
+func buildInfo() map[string]string {
+	info := map[string]string{"version": version}
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	info["go_version"] = bi.GoVersion
+	for _, setting := range bi.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			info["revision"] = setting.Value
+		case "vcs.time":
+			info["revision_time"] = setting.Value
+		case "vcs.modified":
+			info["modified"] = setting.Value
+		}
+	}
+	return info
+}
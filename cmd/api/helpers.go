@@ -11,6 +11,7 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
 	"Quiz3.zioncastillo.net/internal/validator"
 	"github.com/julienschmidt/httprouter"
@@ -72,6 +73,42 @@ func (app *application) readInt(qs url.Values, key string, defaultValue int, v *
 	return intValue
 }
 
+// The readBool() method converts a string value from the query string to a
+// *bool. A missing key returns nil so callers can tell "not filtered" apart
+// from false. Invalid values are added to the validation errors map
+func (app *application) readBool(qs url.Values, key string, v *validator.Validator) *bool {
+	// Get the value
+	value := qs.Get(key)
+	if value == "" {
+		return nil
+	}
+	// Perform the conversion to a boolean
+	boolValue, err := strconv.ParseBool(value)
+	if err != nil {
+		v.AddError(key, "must be a boolean value")
+		return nil
+	}
+	return &boolValue
+}
+
+// The readTime() method converts an RFC 3339 timestamp from the query string
+// to a *time.Time. A missing key returns nil and invalid values are added to
+// the validation errors map
+func (app *application) readTime(qs url.Values, key string, v *validator.Validator) *time.Time {
+	// Get the value
+	value := qs.Get(key)
+	if value == "" {
+		return nil
+	}
+	// Perform the conversion to a time
+	timeValue, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		v.AddError(key, "must be an RFC 3339 timestamp")
+		return nil
+	}
+	return &timeValue
+}
+
 func (app *application) writeJSON(w http.ResponseWriter, status int, data envelope, headers http.Header) error {
 	//Convert our map into a JSON object
 	js, err := json.MarshalIndent(data, "", "\t")
@@ -148,3 +185,17 @@ func (app *application) readJSON(w http.ResponseWriter, r *http.Request, dst int
 	return nil
 }
 
+// The background() method runs fn in a new goroutine, recovering from any
+// panic so that it cannot bring down the server. Shutdown waits for it
+func (app *application) background(fn func()) {
+	app.wg.Add(1)
+	go func() {
+		defer app.wg.Done()
+		defer func() {
+			if err := recover(); err != nil {
+				app.logger.PrintError(fmt.Errorf("%s", err), nil)
+			}
+		}()
+		fn()
+	}()
+}
@@ -0,0 +1,211 @@
+// Filename: cmd/api/lists.go
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"Quiz3.zioncastillo.net/internal/data"
+	"Quiz3.zioncastillo.net/internal/validator"
+)
+
+// createListHandler for the "POST /v1/lists" endpoint
+func (app *application) createListHandler(w http.ResponseWriter, r *http.Request) {
+	// Our target decode destination
+	var input struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+	}
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	// The list belongs to whoever creates it
+	list := &data.List{
+		UserID:      app.contextGetUser(r).ID,
+		Name:        input.Name,
+		Description: input.Description,
+	}
+	// Check the map to determine if there were any validation errors
+	v := validator.New()
+	if data.ValidateList(v, list); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	err = app.models.List.Insert(list)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	// Create a Location header for the newly created list
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/lists/%d", list.ID))
+	err = app.writeJSON(w, http.StatusCreated, envelope{"list": list}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// showListHandler for the "GET /v1/lists/:id" endpoint
+func (app *application) showListHandler(w http.ResponseWriter, r *http.Request) {
+	list, ok := app.readList(w, r)
+	if !ok {
+		return
+	}
+	err := app.writeJSON(w, http.StatusOK, envelope{"list": list}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// updateListHandler for the "PATCH /v1/lists/:id" endpoint
+func (app *application) updateListHandler(w http.ResponseWriter, r *http.Request) {
+	list, ok := app.readList(w, r)
+	if !ok {
+		return
+	}
+	// Shared lists are visible to everyone but only admins may change them
+	ownerID := app.todoOwnerID(r)
+	if ownerID != 0 && list.UserID != ownerID {
+		app.notPermittedResponse(w, r)
+		return
+	}
+	// Create an input struct to hold data read in from the client
+	var input struct {
+		Name        *string `json:"name"`
+		Description *string `json:"description"`
+	}
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if input.Name != nil {
+		list.Name = *input.Name
+	}
+	if input.Description != nil {
+		list.Description = *input.Description
+	}
+	v := validator.New()
+	if data.ValidateList(v, list); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	err = app.models.List.Update(list, ownerID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"list": list}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// deleteListHandler for the "DELETE /v1/lists/:id" endpoint. All items in
+// the list are deleted with it, whoever owns them, so users may only delete
+// their own lists and shared lists are left to admins
+func (app *application) deleteListHandler(w http.ResponseWriter, r *http.Request) {
+	list, ok := app.readList(w, r)
+	if !ok {
+		return
+	}
+	ownerID := app.todoOwnerID(r)
+	if ownerID != 0 && list.UserID != ownerID {
+		app.notPermittedResponse(w, r)
+		return
+	}
+	err := app.models.List.Delete(list.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrDefaultList):
+			app.defaultListResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "List successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// listListsHandler for the "GET /v1/lists" endpoint
+func (app *application) listListsHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name string
+		data.Filters
+	}
+	v := validator.New()
+	qs := r.URL.Query()
+	input.Name = app.readString(qs, "name", "")
+	// Get the page information
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	// Get the sort information
+	input.Filters.Sort = app.readString(qs, "sort", "id")
+	input.Filters.SortList = []string{"id", "name", "item_count", "-id", "-name", "-item_count"}
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	lists, metadata, err := app.models.List.GetAll(app.todoOwnerID(r), input.Name, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"lists": lists, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// createListItemHandler for the "POST /v1/lists/:id/items" endpoint
+func (app *application) createListItemHandler(w http.ResponseWriter, r *http.Request) {
+	list, ok := app.readList(w, r)
+	if !ok {
+		return
+	}
+	app.createTodo(w, r, list.ID)
+}
+
+// listListItemsHandler for the "GET /v1/lists/:id/items" endpoint
+func (app *application) listListItemsHandler(w http.ResponseWriter, r *http.Request) {
+	list, ok := app.readList(w, r)
+	if !ok {
+		return
+	}
+	app.listTodos(w, r, list.ID)
+}
+
+// readList() fetches the list named by the id parameter, if the user may
+// see it. If it cannot be found an error response has already been sent and
+// ok is false
+func (app *application) readList(w http.ResponseWriter, r *http.Request) (*data.List, bool) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+	list, err := app.models.List.Get(id, app.todoOwnerID(r))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+	return list, true
+}
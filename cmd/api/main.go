@@ -2,15 +2,23 @@ package main
 
 import (
     "context"
+    "crypto/rand"
     "database/sql"
+    "errors"
     "flag"
     "fmt"
-    "log"
-    "net/http"
+    "log/slog"
+    "net"
     "os"
+    "slices"
+    "strings"
+    "sync"
     "time"
 
 	"Quiz3.zioncastillo.net/internal/data"
+	"Quiz3.zioncastillo.net/internal/jsonlog"
+	"Quiz3.zioncastillo.net/internal/mailer"
+	"Quiz3.zioncastillo.net/internal/migrate"
     _ "github.com/lib/pq"
 )
 
@@ -27,13 +35,54 @@ const version = "1.0.0"
 
 type config struct {
     port int
+	shutdownTimeout time.Duration
+	adminAddr string
     env  string	
+	storage string
+	log struct {
+		level       string
+		traceLevels string
+		traceDepth  int
+		redactKeys  string
+		file        struct {
+			path       string
+			level      string
+			maxSize    int64
+			interval   time.Duration
+			maxBackups int
+			maxAge     time.Duration
+			compress   bool
+		}
+	}
     db struct {
+		driver string
         dsn string
 		maxOpenConns int
         maxIdleConns int
         maxIdleTime string
+		autoMigrate bool
+		queryTimeout time.Duration
     }
+	limiter struct {
+		enabled        bool
+		rps            float64
+		burst          int
+		trustedProxies []*net.IPNet
+	}
+	cors struct {
+		trustedOrigins []string
+	}
+	// cursor.key signs the pagination cursors handed to clients
+	cursor struct {
+		key []byte
+	}
+	smtp struct {
+		host     string
+		port     int
+		username string
+		password string
+		sender   string
+	}
 }
 
 // Define an application struct to hold the dependencies for our HTTP handlers, helpers,
@@ -41,8 +90,20 @@ type config struct {
 // logger, but it will grow to include a lot more as our build progresses.
 type application struct {
     config config
-    logger *log.Logger
+    logger *jsonlog.Logger
+	db     *sql.DB
 	models data.Models
+	mailer mailer.Sender
+	// startedAt is reported as uptime by the healthchecks
+	startedAt time.Time
+	stats     *metrics
+	// limiter is nil when rate limiting is disabled
+	limiter *rateLimiter
+	// wg tracks goroutines started by background() so shutdown can wait
+	wg     sync.WaitGroup
+	// migrationWarning keeps the readiness check from repeating that the
+	// migration version is unreadable
+	migrationWarning sync.Once
 }
 
 func main() {
@@ -53,52 +114,225 @@ func main() {
     // corresponding flags are provided.
     flag.IntVar(&cfg.port, "port", 4000, "API server port")
     flag.StringVar(&cfg.env, "env", "development", "Environment (development|staging|production)")
-    flag.StringVar(&cfg.db.dsn, "db-dsn", os.Getenv("TODO_DB_DSN"), "PostgreSQL DSN")
+	flag.StringVar(&cfg.storage, "storage", "database", "Where todo items are kept (database). The in-memory store is only for tests")
+	flag.StringVar(&cfg.adminAddr, "admin-addr", "localhost:4001", "Address for the /metrics and /debug/vars endpoints (disabled if empty)")
+	flag.DurationVar(&cfg.shutdownTimeout, "shutdown-timeout", 20*time.Second, "Time allowed for in-flight requests and background tasks on shutdown")
+	flag.StringVar(&cfg.log.level, "log-level", "info", "Minimum log level (debug|info|warn|error|fatal|off)")
+	flag.StringVar(&cfg.log.traceLevels, "log-trace-levels", "error,fatal", "Comma separated levels which include a stack trace")
+	flag.IntVar(&cfg.log.traceDepth, "log-trace-depth", 32, "Maximum stack trace frames (0 = unlimited)")
+	flag.StringVar(&cfg.log.redactKeys, "log-redact-keys", strings.Join(jsonlog.DefaultRedactKeys, ","), "Comma separated property keys to mask in logs")
+
+	// An optional rotating log file, written alongside standard out
+	flag.StringVar(&cfg.log.file.path, "log-file", "", "Also write logs to this file (disabled if empty)")
+	flag.StringVar(&cfg.log.file.level, "log-file-level", "debug", "Minimum log level for the log file")
+	flag.Int64Var(&cfg.log.file.maxSize, "log-file-max-size", 100, "Rotate the log file after this many megabytes (0 = never)")
+	flag.DurationVar(&cfg.log.file.interval, "log-file-rotate-interval", 24*time.Hour, "Rotate the log file after this long (0 = never)")
+	flag.IntVar(&cfg.log.file.maxBackups, "log-file-max-backups", 7, "Number of rotated log files to keep (0 = all)")
+	flag.DurationVar(&cfg.log.file.maxAge, "log-file-max-age", 30*24*time.Hour, "Delete rotated log files older than this (0 = never)")
+	flag.BoolVar(&cfg.log.file.compress, "log-file-compress", true, "Gzip rotated log files")
+	flag.StringVar(&cfg.db.driver, "db-driver", "postgres", "Database backend (postgres|sqlite). SQLite needs a build with -tags sqlite_fts5")
+    flag.StringVar(&cfg.db.dsn, "db-dsn", os.Getenv("TODO_DB_DSN"), "PostgreSQL DSN, or the SQLite database file")
     flag.IntVar(&cfg.db.maxOpenConns, "db-max-open-conns", 25, "PostgreSQL max open connections")
 	flag.IntVar(&cfg.db.maxIdleConns, "db-max-idle-conns", 25, "PostgreSQL max idle connection")
 	flag.StringVar(&cfg.db.maxIdleTime, "db-max-idle-time", "15m", "PostgreSQL max connection idle time")
+	flag.DurationVar(&cfg.db.queryTimeout, "db-query-timeout", 3*time.Second, "Maximum time for a single todo query (0 = until the client goes away)")
+	flag.BoolVar(&cfg.db.autoMigrate, "db-auto-migrate", false, "Apply pending migrations on startup")
+
+	flag.BoolVar(&cfg.limiter.enabled, "limiter-enabled", true, "Enable rate limiter")
+	flag.Float64Var(&cfg.limiter.rps, "limiter-rps", 2, "Rate limiter maximum requests per second")
+	flag.IntVar(&cfg.limiter.burst, "limiter-burst", 4, "Rate limiter maximum burst")
+	flag.Func("limiter-trusted-proxies", "Comma separated IPs or CIDRs whose X-Forwarded-For header is trusted", func(val string) error {
+		for _, entry := range strings.Split(val, ",") {
+			entry = strings.TrimSpace(entry)
+			if entry == "" {
+				continue
+			}
+			// A bare address is a network of one
+			if !strings.Contains(entry, "/") {
+				if strings.Contains(entry, ":") {
+					entry += "/128"
+				} else {
+					entry += "/32"
+				}
+			}
+			_, network, err := net.ParseCIDR(entry)
+			if err != nil {
+				return err
+			}
+			cfg.limiter.trustedProxies = append(cfg.limiter.trustedProxies, network)
+		}
+		return nil
+	})
+
+	flag.Func("cors-trusted-origins", "Trusted CORS origins (comma or space separated)", func(val string) error {
+		cfg.cors.trustedOrigins = strings.FieldsFunc(val, func(r rune) bool {
+			return r == ',' || r == ' '
+		})
+		return nil
+	})
 
-	flag.Parse()
+	flag.Func("cursor-secret", "Key for signing pagination cursors (random if unset, so cursors end with the process)", func(val string) error {
+		cfg.cursor.key = []byte(val)
+		return nil
+	})
 
-    // Initialize a new logger which writes messages to the standard out stream, 
-    // prefixed with the current date and time.
-    logger := log.New(os.Stdout, "", log.Ldate | log.Ltime)
+	// The SMTP defaults point at a local MailHog instance
+	flag.StringVar(&cfg.smtp.host, "smtp-host", "localhost", "SMTP host")
+	flag.IntVar(&cfg.smtp.port, "smtp-port", 1025, "SMTP port")
+	flag.StringVar(&cfg.smtp.username, "smtp-username", os.Getenv("TODO_SMTP_USERNAME"), "SMTP username")
+	flag.StringVar(&cfg.smtp.password, "smtp-password", os.Getenv("TODO_SMTP_PASSWORD"), "SMTP password")
+	flag.StringVar(&cfg.smtp.sender, "smtp-sender", "Todo List <no-reply@todo.zioncastillo.net>", "SMTP sender")
+
+	// "api migrate <action>" runs migrations instead of the server. Flags
+	// come after the action name is taken off, as in "api migrate -db-dsn=... up"
+	migrateMode := len(os.Args) > 1 && os.Args[1] == "migrate"
+	if migrateMode {
+		flag.CommandLine.Parse(os.Args[2:])
+	} else {
+		flag.Parse()
+	}
+
+	// "postgres" was the name of the database storage before -db-driver
+	if cfg.storage == "memory" {
+		// Lists count and cascade to their items in SQL, and users and tokens
+		// need the database anyway, so a server on the memory store would be
+		// inconsistent. Tests use data.NewMemoryTodoStore() directly
+		fmt.Fprintln(os.Stderr, "-storage=memory is only available to tests")
+		os.Exit(2)
+	}
+	if cfg.storage != "database" && cfg.storage != "postgres" {
+		fmt.Fprintf(os.Stderr, "invalid -storage value %q\n", cfg.storage)
+		os.Exit(2)
+	}
+	if cfg.db.driver != "postgres" && cfg.db.driver != "sqlite" {
+		fmt.Fprintf(os.Stderr, "invalid -db-driver value %q\n", cfg.db.driver)
+		os.Exit(2)
+	}
+
+    // Initialize a new jsonlog.Logger which writes any messages *at or above* the
+    // -log-level severity to the standard out stream.
+    minLevel, err := jsonlog.ParseLevel(cfg.log.level)
+    if err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        os.Exit(2)
+    }
+    var traceLevels []jsonlog.Level
+    for _, name := range strings.Split(cfg.log.traceLevels, ",") {
+        if name == "" {
+            continue
+        }
+        level, err := jsonlog.ParseLevel(strings.TrimSpace(name))
+        if err != nil {
+            fmt.Fprintln(os.Stderr, err)
+            os.Exit(2)
+        }
+        traceLevels = append(traceLevels, level)
+    }
+    logOptions := []jsonlog.Option{
+        jsonlog.WithTraceLevels(traceLevels...),
+        jsonlog.WithTraceDepth(cfg.log.traceDepth),
+        jsonlog.WithRedactKeys(strings.Split(cfg.log.redactKeys, ",")...),
+    }
+    if cfg.log.file.path != "" {
+        fileLevel, err := jsonlog.ParseLevel(cfg.log.file.level)
+        if err != nil {
+            fmt.Fprintln(os.Stderr, err)
+            os.Exit(2)
+        }
+        logFile, err := jsonlog.NewRotatingFile(cfg.log.file.path, jsonlog.RotateConfig{
+            MaxSize:    cfg.log.file.maxSize * 1024 * 1024,
+            Interval:   cfg.log.file.interval,
+            MaxBackups: cfg.log.file.maxBackups,
+            MaxAge:     cfg.log.file.maxAge,
+            Compress:   cfg.log.file.compress,
+        })
+        if err != nil {
+            fmt.Fprintln(os.Stderr, err)
+            os.Exit(2)
+        }
+        defer logFile.Close()
+        logOptions = append(logOptions, jsonlog.WithSink(logFile, fileLevel))
+    }
+    logger := jsonlog.New(os.Stdout, minLevel, logOptions...)
+	if len(cfg.cursor.key) == 0 {
+		cfg.cursor.key = []byte(os.Getenv("TODO_CURSOR_SECRET"))
+	}
+	if len(cfg.cursor.key) == 0 {
+		cfg.cursor.key = make([]byte, 32)
+		_, err := rand.Read(cfg.cursor.key)
+		if err != nil {
+			logger.PrintFatal(err, nil)
+		}
+		// Each instance signs with a different key, and cursors stop working
+		// on restart
+		logger.PrintWarn("no cursor secret set, using a random key", map[string]any{
+			"hint": "set -cursor-secret or TODO_CURSOR_SECRET",
+		})
+	}
+    // Route log/slog, and the standard log package behind it, through the same
+    // JSON stream so library output has the same shape as ours
+    slog.SetDefault(slog.New(jsonlog.NewHandler(logger)))
 
     // Create a connection pool
     db, err := openDB(cfg)
     if err != nil {
-        logger.Fatal(err)
+        logger.PrintFatal(err, nil)
     }
     defer db.Close()
-	logger.Println("datatbase connection pool established")
+	logger.PrintInfo("database connection pool established", nil)
+
+	if migrateMode {
+		err = runMigrate(db, cfg.db.driver, logger, flag.Args())
+		if err != nil {
+			logger.PrintFatal(err, nil)
+		}
+		return
+	}
+	if cfg.db.autoMigrate {
+		migrator, err := newMigrator(db, cfg.db.driver, logger)
+		if err != nil {
+			logger.PrintFatal(err, nil)
+		}
+		err = migrator.Up(context.Background())
+		if err != nil && !errors.Is(err, migrate.ErrNoChange) {
+			logger.PrintFatal(err, nil)
+		}
+	}
     // Declare an instance of the application struct, containing the config struct and 
     // the logger.
     app := &application{
 		config: cfg,
 		logger: logger,
-		models: data.NewModels(db),
-	}
-
-    // Declare a HTTP server with some sensible timeout settings, which listens on the
-    // port provided in the config struct and uses the servemux we created above as the 
-    // handler.
-    srv := &http.Server{
-        Addr:         fmt.Sprintf(":%d", cfg.port),
-        Handler:      app.routes(),
-        IdleTimeout:  time.Minute,
-        ReadTimeout:  10 * time.Second,
-        WriteTimeout: 30 * time.Second,
-    }
+		db:     db,
+		models: newModels(db, cfg),
+		mailer: mailer.New(cfg.smtp.host, cfg.smtp.port, cfg.smtp.username, cfg.smtp.password, cfg.smtp.sender),
+		startedAt: time.Now(),
+		stats:     newMetrics(),
+	}
+	if cfg.limiter.enabled {
+		app.limiter = newRateLimiter(cfg.limiter.rps, cfg.limiter.burst)
+	}
+	app.publishMetrics()
 
-    // Start the HTTP server.
-    logger.Printf("starting %s server on %s", cfg.env, srv.Addr)
-    err = srv.ListenAndServe()
-    logger.Fatal(err)
+    // Start the HTTP server. serve() only returns once shutdown has finished,
+    // letting the deferred closes above run
+    err = app.serve()
+    if err != nil {
+        logger.PrintFatal(err, nil)
+    }
 }
 
 // Open DB function to return a *sql.DB connection pool
 func openDB(cfg config) (*sql.DB, error) {
-    db, err := sql.Open("postgres", cfg.db.dsn)
+	driver, dsn := "postgres", cfg.db.dsn
+	if cfg.db.driver == "sqlite" {
+		driver, dsn = "sqlite3", sqliteDSN(cfg.db.dsn)
+		// The driver is only registered by builds with the sqlite_fts5 tag
+		if !slices.Contains(sql.Drivers(), driver) {
+			return nil, errors.New("this binary was built without SQLite support, rebuild it with -tags sqlite_fts5")
+		}
+	}
+    db, err := sql.Open(driver, dsn)
 	if err != nil {
 		return nil, err
 	}
@@ -120,4 +354,27 @@ func openDB(cfg config) (*sql.DB, error) {
 	}
 	return db, nil
 
-} 
\ No newline at end of file
+} 
+
+// newModels() returns the models for the configured database driver
+func newModels(db *sql.DB, cfg config) data.Models {
+	if cfg.db.driver == "sqlite" {
+		return data.NewSQLiteModels(db, cfg.db.queryTimeout)
+	}
+	return data.NewModels(db, cfg.db.queryTimeout)
+}
+
+// sqliteDSN() adds the connection settings the SQLite backend relies on to
+// a database file name: enforced foreign keys for the ON DELETE CASCADE
+// rules, WAL so readers do not block the writer, and a wait instead of an
+// immediate error when the database is locked
+func sqliteDSN(path string) string {
+	if path == "" {
+		path = "todo.db"
+	}
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	return path + sep + "_foreign_keys=on&_journal_mode=WAL&_busy_timeout=5000"
+}
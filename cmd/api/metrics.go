@@ -0,0 +1,258 @@
+// Filename: cmd/api/metrics.go
+package main
+
+import (
+	"expvar"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Upper bounds, in seconds, of the request duration histogram buckets
+var durationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// histogram is a cumulative-bucket histogram in the Prometheus style
+type histogram struct {
+	counts []uint64 // counts[i] is the number of observations <= durationBuckets[i]
+	count  uint64
+	sum    float64
+}
+
+func (h *histogram) observe(seconds float64) {
+	for i, bound := range durationBuckets {
+		if seconds <= bound {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += seconds
+}
+
+// routeKey identifies a route by method and pattern, such as "/v1/list/:id"
+type routeKey struct {
+	method string
+	route  string
+}
+
+// metrics holds the counters recorded by the metrics middleware
+type metrics struct {
+	mu                 sync.Mutex
+	requestsReceived   uint64
+	responsesSent      uint64
+	responsesByStatus  map[int]uint64
+	processingTimeUsec uint64
+	durations          map[routeKey]*histogram
+	// routes holds the registered patterns for each method, split into
+	// segments. It is filled in by routes() before the server starts
+	routes map[string][][]string
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		responsesByStatus: make(map[int]uint64),
+		durations:         make(map[routeKey]*histogram),
+		routes:            make(map[string][][]string),
+	}
+}
+
+// addRoute() records a pattern registered with the router
+func (m *metrics) addRoute(method, pattern string) {
+	m.routes[method] = append(m.routes[method], strings.Split(pattern, "/"))
+}
+
+func (m *metrics) record(key routeKey, status int, duration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.responsesSent++
+	m.responsesByStatus[status]++
+	m.processingTimeUsec += uint64(duration.Microseconds())
+	h, ok := m.durations[key]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(durationBuckets))}
+		m.durations[key] = h
+	}
+	h.observe(duration.Seconds())
+}
+
+// metrics() counts requests and responses and times each route. Only
+// routes known to the router get their own histogram, so random URLs
+// cannot blow up the number of series
+func (app *application) metrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		app.stats.mu.Lock()
+		app.stats.requestsReceived++
+		app.stats.mu.Unlock()
+
+		rw := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rw, r)
+
+		app.stats.record(routeKey{method: r.Method, route: app.stats.routePattern(r.Method, r.URL.Path)}, rw.status, time.Since(start))
+	})
+}
+
+// routePattern() returns the registered pattern, such as "/v1/list/:id",
+// which matches the path. As in httprouter, a static segment wins over a
+// parameter in the same place
+func (m *metrics) routePattern(method, path string) string {
+	segments := strings.Split(path, "/")
+	var best []string
+	for _, pattern := range m.routes[method] {
+		if matchSegments(pattern, segments) && (best == nil || moreSpecific(pattern, best)) {
+			best = pattern
+		}
+	}
+	if best == nil {
+		return "unmatched"
+	}
+	return strings.Join(best, "/")
+}
+
+func matchSegments(pattern, segments []string) bool {
+	for i, p := range pattern {
+		switch {
+		case strings.HasPrefix(p, "*"):
+			return true
+		case i >= len(segments):
+			return false
+		case strings.HasPrefix(p, ":"):
+			if segments[i] == "" {
+				return false
+			}
+		case p != segments[i]:
+			return false
+		}
+	}
+	return len(pattern) == len(segments)
+}
+
+// moreSpecific() reports whether pattern a has a static segment where b
+// first has a parameter
+func moreSpecific(a, b []string) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		aStatic := !strings.HasPrefix(a[i], ":") && !strings.HasPrefix(a[i], "*")
+		bStatic := !strings.HasPrefix(b[i], ":") && !strings.HasPrefix(b[i], "*")
+		if aStatic != bStatic {
+			return aStatic
+		}
+	}
+	return false
+}
+
+// publishMetrics() exposes the metrics through expvar at /debug/vars. It
+// must only be called once
+func (app *application) publishMetrics() {
+	expvar.NewString("version").Set(version)
+	expvar.Publish("build", expvar.Func(func() interface{} {
+		return buildInfo()
+	}))
+	expvar.Publish("goroutines", expvar.Func(func() interface{} {
+		return runtime.NumGoroutine()
+	}))
+	expvar.Publish("database", expvar.Func(func() interface{} {
+		return app.db.Stats()
+	}))
+	expvar.Publish("timestamp", expvar.Func(func() interface{} {
+		return time.Now().Unix()
+	}))
+	expvar.Publish("http", expvar.Func(func() interface{} {
+		app.stats.mu.Lock()
+		defer app.stats.mu.Unlock()
+		byStatus := make(map[string]uint64, len(app.stats.responsesByStatus))
+		for status, n := range app.stats.responsesByStatus {
+			byStatus[strconv.Itoa(status)] = n
+		}
+		routes := make(map[string]interface{}, len(app.stats.durations))
+		for key, h := range app.stats.durations {
+			routes[key.method+" "+key.route] = map[string]interface{}{
+				"count":       h.count,
+				"sum_seconds": h.sum,
+			}
+		}
+		return map[string]interface{}{
+			"total_requests_received":        app.stats.requestsReceived,
+			"total_responses_sent":           app.stats.responsesSent,
+			"total_processing_time_μs":       app.stats.processingTimeUsec,
+			"total_responses_sent_by_status": byStatus,
+			"routes":                         routes,
+		}
+	}))
+}
+
+// prometheusHandler serves the metrics in the Prometheus text format
+func (app *application) prometheusHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	app.stats.mu.Lock()
+	writeMetric(w, "http_requests_total", "counter", "Total HTTP requests received.")
+	fmt.Fprintf(w, "http_requests_total %d\n", app.stats.requestsReceived)
+
+	writeMetric(w, "http_responses_total", "counter", "Total HTTP responses sent by status code.")
+	statuses := make([]int, 0, len(app.stats.responsesByStatus))
+	for status := range app.stats.responsesByStatus {
+		statuses = append(statuses, status)
+	}
+	sort.Ints(statuses)
+	for _, status := range statuses {
+		fmt.Fprintf(w, "http_responses_total{code=\"%d\"} %d\n", status, app.stats.responsesByStatus[status])
+	}
+
+	writeMetric(w, "http_request_duration_seconds", "histogram", "HTTP request processing time by route.")
+	keys := make([]routeKey, 0, len(app.stats.durations))
+	for key := range app.stats.durations {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].route != keys[j].route {
+			return keys[i].route < keys[j].route
+		}
+		return keys[i].method < keys[j].method
+	})
+	for _, key := range keys {
+		h := app.stats.durations[key]
+		labels := fmt.Sprintf("method=%q,route=%q", key.method, key.route)
+		for i, bound := range durationBuckets {
+			fmt.Fprintf(w, "http_request_duration_seconds_bucket{%s,le=\"%s\"} %d\n", labels, strconv.FormatFloat(bound, 'g', -1, 64), h.counts[i])
+		}
+		fmt.Fprintf(w, "http_request_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels, h.count)
+		fmt.Fprintf(w, "http_request_duration_seconds_sum{%s} %g\n", labels, h.sum)
+		fmt.Fprintf(w, "http_request_duration_seconds_count{%s} %d\n", labels, h.count)
+	}
+	app.stats.mu.Unlock()
+
+	writeMetric(w, "go_goroutines", "gauge", "Number of goroutines that currently exist.")
+	fmt.Fprintf(w, "go_goroutines %d\n", runtime.NumGoroutine())
+
+	stats := app.db.Stats()
+	writeMetric(w, "sql_db_max_open_connections", "gauge", "Maximum number of open connections to the database.")
+	fmt.Fprintf(w, "sql_db_max_open_connections %d\n", stats.MaxOpenConnections)
+	writeMetric(w, "sql_db_open_connections", "gauge", "Number of established connections, in use and idle.")
+	fmt.Fprintf(w, "sql_db_open_connections %d\n", stats.OpenConnections)
+	writeMetric(w, "sql_db_in_use_connections", "gauge", "Number of connections currently in use.")
+	fmt.Fprintf(w, "sql_db_in_use_connections %d\n", stats.InUse)
+	writeMetric(w, "sql_db_idle_connections", "gauge", "Number of idle connections.")
+	fmt.Fprintf(w, "sql_db_idle_connections %d\n", stats.Idle)
+	writeMetric(w, "sql_db_wait_count_total", "counter", "Total number of connections waited for.")
+	fmt.Fprintf(w, "sql_db_wait_count_total %d\n", stats.WaitCount)
+	writeMetric(w, "sql_db_wait_duration_seconds_total", "counter", "Total time blocked waiting for a new connection.")
+	fmt.Fprintf(w, "sql_db_wait_duration_seconds_total %g\n", stats.WaitDuration.Seconds())
+}
+
+func writeMetric(w io.Writer, name, kind, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// adminRoutes() serves the metrics endpoints, which are only bound to the
+// admin address and never to the public port
+func (app *application) adminRoutes() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/debug/vars", expvar.Handler())
+	mux.HandleFunc("/metrics", app.prometheusHandler)
+	return mux
+}
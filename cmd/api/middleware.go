@@ -0,0 +1,295 @@
+// Filename: cmd/api/middleware.go
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"Quiz3.zioncastillo.net/internal/data"
+	"Quiz3.zioncastillo.net/internal/validator"
+)
+
+// recoverPanic() turns a panic in a handler, such as an unsafe sort
+// parameter or a bad readJSON() destination, into a JSON 500 response
+func (app *application) recoverPanic(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// This deferred function always runs, even while unwinding a panic
+		defer func() {
+			if err := recover(); err != nil {
+				// Tell Go's HTTP server to close the connection after responding
+				w.Header().Set("Connection", "close")
+				// jsonlog attaches the stack trace, which still includes the panic
+				app.serverErrorResponse(w, r, fmt.Errorf("%s", err))
+			}
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// responseRecorder wraps an http.ResponseWriter to remember the status
+// code and the number of bytes written
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	bytes       int
+	wroteHeader bool
+}
+
+func (rw *responseRecorder) WriteHeader(status int) {
+	if !rw.wroteHeader {
+		rw.status = status
+		rw.wroteHeader = true
+	}
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *responseRecorder) Write(b []byte) (int, error) {
+	// A handler that writes without calling WriteHeader() gets a 200
+	if !rw.wroteHeader {
+		rw.WriteHeader(http.StatusOK)
+	}
+	n, err := rw.ResponseWriter.Write(b)
+	rw.bytes += n
+	return n, err
+}
+
+// Unwrap() lets http.ResponseController reach the underlying writer
+func (rw *responseRecorder) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// logRequest() writes one log entry per request once it has been handled
+func (app *application) logRequest(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rw := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(rw, r)
+
+		app.logger.PrintInfo("request completed", map[string]any{
+			"request_method": r.Method,
+			"request_url":    r.URL.String(),
+			"status":         rw.status,
+			"bytes":          rw.bytes,
+			"duration_ms":    float64(time.Since(start).Microseconds()) / 1000,
+			"remote_addr":    r.RemoteAddr,
+		})
+	})
+}
+
+// authenticate() looks up the user for the bearer token in the
+// Authorization header. Requests without a header carry the AnonymousUser.
+// rateLimit() runs later, so invalid tokens are limited here: once a token
+// has failed the lookup it is charged to the client IP's bucket. Valid tokens
+// never touch that bucket, so users behind one NAT are only limited by their
+// own
+func (app *application) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The response depends on the Authorization header
+		w.Header().Add("Vary", "Authorization")
+
+		authorizationHeader := r.Header.Get("Authorization")
+		if authorizationHeader == "" {
+			r = app.contextSetUser(r, data.AnonymousUser)
+			next.ServeHTTP(w, r)
+			return
+		}
+		invalidToken := func() {
+			if app.limiter != nil && !app.takeRateLimit(w, r, "ip:"+app.clientIP(r)) {
+				return
+			}
+			app.invalidAuthenticationTokenResponse(w, r)
+		}
+		// Expect the format "Bearer <token>"
+		headerParts := strings.Split(authorizationHeader, " ")
+		if len(headerParts) != 2 || headerParts[0] != "Bearer" {
+			invalidToken()
+			return
+		}
+		token := headerParts[1]
+
+		v := validator.New()
+		if data.ValidateTokenPlaintext(v, token); !v.Valid() {
+			invalidToken()
+			return
+		}
+		user, err := app.models.User.GetForToken(data.ScopeAuthentication, token)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				invalidToken()
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+		r = app.contextSetUser(r, user)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireAuthenticatedUser() rejects anonymous requests
+func (app *application) requireAuthenticatedUser(next http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := app.contextGetUser(r)
+		if user.IsAnonymous() {
+			app.authenticationRequiredResponse(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireActivatedUser() rejects anonymous requests and users who have not
+// activated their account
+func (app *application) requireActivatedUser(next http.HandlerFunc) http.HandlerFunc {
+	fn := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := app.contextGetUser(r)
+		if !user.Activated {
+			app.inactiveAccountResponse(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+	return app.requireAuthenticatedUser(fn)
+}
+
+// requirePermission() rejects users who have not been granted the
+// permission code. The user's permissions are kept in the request context
+func (app *application) requirePermission(code string, next http.HandlerFunc) http.HandlerFunc {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		user := app.contextGetUser(r)
+		permissions, err := app.models.Permission.GetAllForUser(user.ID)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		if !permissions.Include(code) {
+			app.notPermittedResponse(w, r)
+			return
+		}
+		r = app.contextSetPermissions(r, permissions)
+		next.ServeHTTP(w, r)
+	}
+	return app.requireActivatedUser(fn)
+}
+
+// rateLimit() applies a token bucket per client. Authenticated requests are
+// keyed by user id so that users behind one NAT do not share a bucket, all
+// others by client IP. It must run after authenticate()
+func (app *application) rateLimit(next http.Handler) http.Handler {
+	if app.limiter == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := "ip:" + app.clientIP(r)
+		if user := app.contextGetUser(r); !user.IsAnonymous() {
+			key = "user:" + strconv.FormatInt(user.ID, 10)
+		}
+		if !app.takeRateLimit(w, r, key) {
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// takeRateLimit() takes a token from the key's bucket and sets the
+// RateLimit-* headers. When the bucket is empty it sends the 429 response,
+// with Retry-After, and returns false
+func (app *application) takeRateLimit(w http.ResponseWriter, r *http.Request, key string) bool {
+	allowed, remaining := app.limiter.take(key)
+
+	// Seconds until the bucket has at least one token again
+	reset := 0
+	if remaining < 1 && app.config.limiter.rps > 0 {
+		reset = int(math.Ceil((1 - remaining) / app.config.limiter.rps))
+	}
+	w.Header().Set("RateLimit-Limit", strconv.Itoa(app.config.limiter.burst))
+	w.Header().Set("RateLimit-Remaining", strconv.Itoa(int(math.Max(0, math.Floor(remaining)))))
+	w.Header().Set("RateLimit-Reset", strconv.Itoa(reset))
+
+	if !allowed {
+		w.Header().Set("Retry-After", strconv.Itoa(reset))
+		app.rateLimitExceededResponse(w, r)
+		return false
+	}
+	return true
+}
+
+// clientIP() returns the address of the client. X-Forwarded-For is only
+// believed when the request came from a trusted proxy, and then the entries
+// are read right to left until the first untrusted address
+func (app *application) clientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	if !app.isTrustedProxy(ip) {
+		return ip
+	}
+	forwarded := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(forwarded[i])
+		if net.ParseIP(hop) == nil {
+			// Anything that is not an address cannot be trusted further
+			break
+		}
+		ip = hop
+		if !app.isTrustedProxy(hop) {
+			break
+		}
+	}
+	return ip
+}
+
+func (app *application) isTrustedProxy(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, network := range app.config.limiter.trustedProxies {
+		if network.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// enableCORS() lets the Elm frontend, served from another origin, call the
+// API. Only origins listed in -cors-trusted-origins are allowed
+func (app *application) enableCORS(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The response depends on the Origin header, even when we do not allow it
+		w.Header().Add("Vary", "Origin")
+		w.Header().Add("Vary", "Access-Control-Request-Method")
+
+		origin := r.Header.Get("Origin")
+		if origin != "" {
+			for i := range app.config.cors.trustedOrigins {
+				if origin != app.config.cors.trustedOrigins[i] {
+					continue
+				}
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+				w.Header().Set("Access-Control-Expose-Headers", "Location, RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset, Retry-After")
+
+				// A preflight request is an OPTIONS request carrying
+				// Access-Control-Request-Method
+				if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+					w.Header().Set("Access-Control-Allow-Methods", "OPTIONS, PUT, PATCH, DELETE")
+					w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+					w.Header().Set("Access-Control-Max-Age", "60")
+					w.WriteHeader(http.StatusOK)
+					return
+				}
+				break
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,98 @@
+// Filename: cmd/api/migrate.go
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"Quiz3.zioncastillo.net/internal/jsonlog"
+	"Quiz3.zioncastillo.net/internal/migrate"
+	"Quiz3.zioncastillo.net/migrations"
+)
+
+const migrateUsage = "usage: api migrate [flags] up|down|status|goto N|force N"
+
+// newMigrator() returns a migrator with the migration set for the
+// -db-driver backend
+func newMigrator(db *sql.DB, driver string, logger *jsonlog.Logger) (*migrate.Migrator, error) {
+	if driver == "sqlite" {
+		return migrate.New(db, "sqlite3", migrations.SQLite, logger)
+	}
+	return migrate.New(db, "postgres", migrations.FS, logger)
+}
+
+// runMigrate() carries out one "api migrate" action against db
+func runMigrate(db *sql.DB, driver string, logger *jsonlog.Logger, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+	migrator, err := newMigrator(db, driver, logger)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	// goto and force take a version number
+	var target int64
+	switch args[0] {
+	case "goto", "force":
+		if len(args) != 2 {
+			return errors.New(migrateUsage)
+		}
+		target, err = strconv.ParseInt(args[1], 10, 64)
+		if err != nil || target < 0 {
+			return fmt.Errorf("invalid version %q", args[1])
+		}
+	default:
+		if len(args) != 1 {
+			return errors.New(migrateUsage)
+		}
+	}
+
+	switch args[0] {
+	case "up":
+		err = migrator.Up(ctx)
+	case "down":
+		err = migrator.Down(ctx)
+	case "goto":
+		err = migrator.Goto(ctx, target)
+	case "force":
+		err = migrator.Force(ctx, target)
+	case "status":
+		return printMigrationStatus(ctx, migrator)
+	default:
+		return errors.New(migrateUsage)
+	}
+	if errors.Is(err, migrate.ErrNoChange) {
+		logger.PrintInfo("no migrations to apply", nil)
+		return nil
+	}
+	return err
+}
+
+// printMigrationStatus() writes a table of migrations to standard out
+func printMigrationStatus(ctx context.Context, migrator *migrate.Migrator) error {
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tNAME\tAPPLIED AT")
+	for _, s := range statuses {
+		appliedAt := "pending"
+		if s.AppliedAt != nil {
+			appliedAt = s.AppliedAt.Format(time.RFC3339)
+		}
+		if s.Modified {
+			appliedAt += " (modified since)"
+		}
+		fmt.Fprintf(tw, "%06d\t%s\t%s\n", s.Version, s.Name, appliedAt)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,67 @@
+// Filename: cmd/api/ratelimit.go
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// rateLimiter keeps a token bucket for each client key seen by rateLimit()
+type rateLimiter struct {
+	rps     float64
+	burst   int
+	mu      sync.Mutex
+	clients map[string]*rateClient
+}
+
+type rateClient struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+func newRateLimiter(rps float64, burst int) *rateLimiter {
+	return &rateLimiter{
+		rps:     rps,
+		burst:   burst,
+		clients: make(map[string]*rateClient),
+	}
+}
+
+// take() takes a token from the key's bucket. It reports whether the
+// request is allowed and how many tokens are left
+func (l *rateLimiter) take(key string) (bool, float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	c, found := l.clients[key]
+	if !found {
+		c = &rateClient{limiter: rate.NewLimiter(rate.Limit(l.rps), l.burst)}
+		l.clients[key] = c
+	}
+	c.lastSeen = time.Now()
+	return c.limiter.Allow(), c.limiter.Tokens()
+}
+
+// sweep() forgets clients we have not heard from recently, once a minute,
+// until ctx is cancelled
+func (l *rateLimiter) sweep(ctx context.Context) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			l.mu.Lock()
+			for key, c := range l.clients {
+				if time.Since(c.lastSeen) > 3*time.Minute {
+					delete(l.clients, key)
+				}
+			}
+			l.mu.Unlock()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -4,21 +4,46 @@ package main
 
 import (
 	"net/http"
+
+	"Quiz3.zioncastillo.net/internal/data"
 	"github.com/julienschmidt/httprouter"
 )
-func (app *application) routes () *httprouter.Router{
+func (app *application) routes () http.Handler{
 	
 	router := httprouter.New()
 	
 	router.NotFound = http.HandlerFunc(app.notFoundResponse)
 	router.MethodNotAllowed = http.HandlerFunc(app.methodNotAllowedResponse)
+
+	// handle() registers a route and records its pattern, which labels the
+	// route's metrics
+	handle := func(method, pattern string, handler http.HandlerFunc) {
+		router.HandlerFunc(method, pattern, handler)
+		app.stats.addRoute(method, pattern)
+	}
 	
-	router.HandlerFunc(http.MethodGet, "/v1/healthcheck", app.healthcheckHandler)
-	router.HandlerFunc(http.MethodPost, "/v1/list", app.createTodoHandler)
-	router.HandlerFunc(http.MethodGet, "/v1/list", app.listTodoListHandler)
-	router.HandlerFunc(http.MethodGet, "/v1/list/:id", app.showTodoHandler)
-	router.HandlerFunc(http.MethodPatch, "/v1/list/:id", app.updateTodoHandler)
-	router.HandlerFunc(http.MethodDelete, "/v1/list/:id", app.deleteTodoHandler)
+	handle(http.MethodGet, "/v1/healthcheck", app.healthcheckHandler)
+	handle(http.MethodGet, "/v1/healthcheck/live", app.liveHealthcheckHandler)
+	handle(http.MethodGet, "/v1/healthcheck/ready", app.readyHealthcheckHandler)
+	handle(http.MethodPost, "/v1/list", app.requirePermission(data.PermissionTodosWrite, app.createTodoHandler))
+	handle(http.MethodGet, "/v1/list", app.requirePermission(data.PermissionTodosRead, app.listTodoListHandler))
+	handle(http.MethodGet, "/v1/list/:id", app.requirePermission(data.PermissionTodosRead, app.showTodoHandler))
+	handle(http.MethodPatch, "/v1/list/:id", app.requirePermission(data.PermissionTodosWrite, app.updateTodoHandler))
+	handle(http.MethodDelete, "/v1/list/:id", app.requirePermission(data.PermissionTodosWrite, app.deleteTodoHandler))
+	handle(http.MethodPost, "/v1/list/:id/complete", app.requirePermission(data.PermissionTodosWrite, app.completeTodoHandler))
+	handle(http.MethodPost, "/v1/list/:id/reopen", app.requirePermission(data.PermissionTodosWrite, app.reopenTodoHandler))
+
+	handle(http.MethodPost, "/v1/lists", app.requirePermission(data.PermissionTodosWrite, app.createListHandler))
+	handle(http.MethodGet, "/v1/lists", app.requirePermission(data.PermissionTodosRead, app.listListsHandler))
+	handle(http.MethodGet, "/v1/lists/:id", app.requirePermission(data.PermissionTodosRead, app.showListHandler))
+	handle(http.MethodPatch, "/v1/lists/:id", app.requirePermission(data.PermissionTodosWrite, app.updateListHandler))
+	handle(http.MethodDelete, "/v1/lists/:id", app.requirePermission(data.PermissionTodosWrite, app.deleteListHandler))
+	handle(http.MethodPost, "/v1/lists/:id/items", app.requirePermission(data.PermissionTodosWrite, app.createListItemHandler))
+	handle(http.MethodGet, "/v1/lists/:id/items", app.requirePermission(data.PermissionTodosRead, app.listListItemsHandler))
+
+	handle(http.MethodPost, "/v1/users", app.registerUserHandler)
+	handle(http.MethodPut, "/v1/users/activated", app.activateUserHandler)
+	handle(http.MethodPost, "/v1/tokens/authentication", app.createAuthenticationTokenHandler)
 
-	return router
+	return app.metrics(app.logRequest(app.recoverPanic(app.enableCORS(app.authenticate(app.rateLimit(router))))))
 }
\ No newline at end of file
@@ -0,0 +1,129 @@
+// Filename: cmd/api/server.go
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// serve() runs the HTTP server until SIGINT or SIGTERM is received, then
+// stops accepting connections, lets in-flight requests finish and waits
+// for background goroutines before returning
+func (app *application) serve() error {
+	// Declare a HTTP server with some sensible timeout settings
+	srv := &http.Server{
+		Addr:         fmt.Sprintf(":%d", app.config.port),
+		Handler:      app.routes(),
+		IdleTimeout:  time.Minute,
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 30 * time.Second,
+		// Send errors from the HTTP server itself through our JSON logger
+		ErrorLog: log.New(app.logger, "", 0),
+	}
+
+	// The metrics endpoints live on their own server so that they can be
+	// bound to a private address
+	var adminSrv *http.Server
+	if app.config.adminAddr != "" {
+		adminSrv = &http.Server{
+			Addr:         app.config.adminAddr,
+			Handler:      app.adminRoutes(),
+			IdleTimeout:  time.Minute,
+			ReadTimeout:  10 * time.Second,
+			WriteTimeout: 30 * time.Second,
+			ErrorLog:     log.New(app.logger, "", 0),
+		}
+		go func() {
+			app.logger.PrintInfo("starting admin server", map[string]any{
+				"addr": adminSrv.Addr,
+			})
+			err := adminSrv.ListenAndServe()
+			if !errors.Is(err, http.ErrServerClosed) {
+				app.logger.PrintError(err, map[string]any{"addr": adminSrv.Addr})
+			}
+		}()
+	}
+
+	// Goroutines which run for the life of the server stop when
+	// stopBackground() is called at shutdown
+	backgroundCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+	if app.limiter != nil {
+		app.background(func() { app.limiter.sweep(backgroundCtx) })
+	}
+
+	// Shutdown() makes ListenAndServe() return straight away, so the result
+	// of the shutdown is passed back on this channel
+	shutdownError := make(chan error)
+
+	go func() {
+		quit := make(chan os.Signal, 1)
+		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+		s := <-quit
+
+		app.logger.PrintInfo("shutting down server", map[string]any{
+			"signal": s.String(),
+		})
+
+		ctx, cancel := context.WithTimeout(context.Background(), app.config.shutdownTimeout)
+		defer cancel()
+
+		// Stop accepting connections and wait for in-flight requests
+		err := srv.Shutdown(ctx)
+		if err != nil {
+			shutdownError <- err
+			return
+		}
+		if adminSrv != nil {
+			err = adminSrv.Shutdown(ctx)
+			if err != nil {
+				shutdownError <- err
+				return
+			}
+		}
+
+		stopBackground()
+		app.logger.PrintInfo("completing background tasks", map[string]any{
+			"addr": srv.Addr,
+		})
+		// Background tasks share what is left of the shutdown timeout
+		done := make(chan struct{})
+		go func() {
+			app.wg.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+			shutdownError <- nil
+		case <-ctx.Done():
+			shutdownError <- fmt.Errorf("background tasks: %w", ctx.Err())
+		}
+	}()
+
+	app.logger.PrintInfo("starting server", map[string]any{
+		"addr": srv.Addr,
+		"env":  app.config.env,
+	})
+
+	err := srv.ListenAndServe()
+	if !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	err = <-shutdownError
+	if err != nil {
+		return err
+	}
+
+	app.logger.PrintInfo("stopped server", map[string]any{
+		"addr": srv.Addr,
+	})
+	return nil
+}
@@ -0,0 +1,9 @@
+// Filename: cmd/api/sqlite.go
+
+//go:build sqlite_fts5
+
+package main
+
+// The SQLite driver needs cgo, and the search indexes need FTS5, so it is
+// only compiled into builds made with -tags sqlite_fts5
+import _ "github.com/mattn/go-sqlite3"
@@ -1,19 +1,36 @@
 // Filename: cms/api/todo.go
 package main
 import (
+	"encoding/json"
 	"fmt"
 	"errors"
 	"net/http"
+	"strconv"
+	"time"
 
 	"Quiz3.zioncastillo.net/internal/data"
 	"Quiz3.zioncastillo.net/internal/validator"
 )
-// createSchoolHandler for the "POST /v1/schools" endpoint
+// createTodoHandler for the "POST /v1/list" endpoint. Items created here
+// go into the default list
 func (app *application) createTodoHandler(w http.ResponseWriter, r *http.Request) {
+	list, err := app.models.List.GetDefault(app.todoOwnerID(r))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	app.createTodo(w, r, list.ID)
+}
+
+// createTodo() decodes, validates and stores a new item in the given list
+func (app *application) createTodo(w http.ResponseWriter, r *http.Request, listID int64) {
 	// Our target decode destination
 	var input struct{
 		Item        string   `json:"item"`
 		Descript    string   `json:"description"`
+		Completed   bool     `json:"completed"`
+		DueAt       *time.Time     `json:"due_at"`
+		Priority    data.Priority  `json:"priority"`
 	}
 	// Initialize a new json.Decoder instance
 	err := app.readJSON(w, r, &input)
@@ -23,8 +40,19 @@ func (app *application) createTodoHandler(w http.ResponseWriter, r *http.Request
 	}
 	 //Copy the values from the input struct to a new School struct
 	 todo := &data.Todo{
+		ListID: listID,
+		UserID: app.contextGetUser(r).ID,
 	 	Item: input.Item,
 	 	Description: input.Descript,
+		DueAt: input.DueAt,
+		Priority: input.Priority,
+	}
+	// Items are normal priority unless the client says otherwise
+	if todo.Priority == 0 {
+		todo.Priority = data.PriorityNormal
+	}
+	if input.Completed {
+		todo.Complete()
 	}
 	// Initialize a new Validator instance
 	v := validator.New()
@@ -37,13 +65,14 @@ func (app *application) createTodoHandler(w http.ResponseWriter, r *http.Request
 	// // Display the request
 	// fmt.Fprintf(w, "%+v\n", input)
 	// Create a School
-	err = app.models.Todo.Insert(todo)
+	err = app.models.Todo.Insert(r.Context(), todo)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
+		return
 	}
 	// Create a Location header for the newly created resource/School
 	headers := make(http.Header)
-	headers.Set("Location", fmt.Sprintf("/v1/todo/%d", todo.ID))
+	headers.Set("Location", fmt.Sprintf("/v1/list/%d", todo.ID))
 	// Write the JSON response with 201 - Created status code with the body
 	// being the School data and the header being the headers map
 	err = app.writeJSON(w, http.StatusCreated, envelope{"item": todo}, headers)
@@ -60,7 +89,7 @@ func (app *application) showTodoHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	todo, err := app.models.Todo.Get(id)
+	todo, err := app.models.Todo.Get(r.Context(), id, app.todoOwnerID(r))
 	// Handle errors
 	if err != nil {
 		switch {
@@ -87,7 +116,7 @@ func (app *application) updateTodoHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 	// Fetch the orginal record from the database
-	todo, err := app.models.Todo.Get(id)
+	todo, err := app.models.Todo.Get(r.Context(), id, app.todoOwnerID(r))
 	// Handle errors
 	if err != nil {
 		switch {
@@ -98,10 +127,25 @@ func (app *application) updateTodoHandler(w http.ResponseWriter, r *http.Request
 		}
 		return
 	}
+	// If the client sent the version it last saw, make sure it is still current
+	if r.Header.Get("X-Expected-Version") != "" {
+		expectedVersion, err := strconv.ParseInt(r.Header.Get("X-Expected-Version"), 10, 32)
+		if err != nil {
+			app.badRequestResponse(w, r, errors.New("invalid X-Expected-Version header"))
+			return
+		}
+		if int64(todo.Version) != expectedVersion {
+			app.editConflictResponse(w, r)
+			return
+		}
+	}
 	// Create an input struct to hold data read in fro mteh client
 	var input struct {
 		Item       *string   `json:"item"`
 		Descript   *string   `json:"description"`
+		Completed  *bool     `json:"completed"`
+		DueAt      optionalTime   `json:"due_at"`
+		Priority   *data.Priority `json:"priority"`
 	}
 
 	// Initialize a new json.Decoder instance
@@ -116,6 +160,21 @@ func (app *application) updateTodoHandler(w http.ResponseWriter, r *http.Request
 	if input.Descript != nil {
 		todo.Description = *input.Descript
 	}
+	// "due_at": null clears the due date
+	if input.DueAt.Set {
+		todo.DueAt = input.DueAt.Time
+	}
+	if input.Priority != nil {
+		todo.Priority = *input.Priority
+	}
+	// Only touch completed_at when the completion state actually changes
+	if input.Completed != nil && *input.Completed != todo.Completed {
+		if *input.Completed {
+			todo.Complete()
+		} else {
+			todo.Reopen()
+		}
+	}
 
 	// Perform validation on the updated School. If validation fails, then
 	// we send a 422 - Unprocessable Entity respose to the client
@@ -128,9 +187,14 @@ func (app *application) updateTodoHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 	// Pass the updated School record to the Update() method
-	err = app.models.Todo.Update(todo)
+	err = app.models.Todo.Update(r.Context(), todo)
 	if err != nil {
-		app.serverErrorResponse(w, r, err)
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
 		return
 	}
 	// Write the data returned by Get()
@@ -149,7 +213,7 @@ func (app *application) deleteTodoHandler(w http.ResponseWriter, r *http.Request
 	}
 	// Delete the School from the database. Send a 404 Not Found status code to the
 	// client if there is no matching record
-	err = app.models.Todo.Delete(id)
+	err = app.models.Todo.Delete(r.Context(), id, app.todoOwnerID(r))
 	// Handle errors
 	if err != nil {
 		switch {
@@ -167,11 +231,22 @@ func (app *application) deleteTodoHandler(w http.ResponseWriter, r *http.Request
 	}
 }
 
+// listTodoListHandler for the "GET /v1/list" endpoint. Only items in the
+// default list are returned
 func (app *application) listTodoListHandler(w http.ResponseWriter, r *http.Request) {
+	list, err := app.models.List.GetDefault(app.todoOwnerID(r))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	app.listTodos(w, r, list.ID)
+}
+
+// listTodos() sends a filtered, sorted page of the items in the given list
+func (app *application) listTodos(w http.ResponseWriter, r *http.Request, listID int64) {
 	// Create an input struct to hold our query parameters
 	var input struct {
-		Item  string
-		Descript string
+		data.TodoSearch
 		data.Filters
 	}
 	// Initialize a validator
@@ -179,30 +254,124 @@ func (app *application) listTodoListHandler(w http.ResponseWriter, r *http.Reque
 	// Get the URL values map
 	qs := r.URL.Query()
 	// Use the helper methods to extract the values
+	input.ListID = listID
 	input.Item = app.readString(qs, "item", "")
-	input.Descript = app.readString(qs, "description", "")
-	// Get the page information
+	input.Description = app.readString(qs, "description", "")
+	input.Completed = app.readBool(qs, "completed", v)
+	input.DueBefore = app.readTime(qs, "due_before", v)
+	input.DueAfter = app.readTime(qs, "due_after", v)
+	input.Overdue = app.readBool(qs, "overdue", v)
+	if priority := app.readString(qs, "priority", ""); priority != "" {
+		p, err := data.ParsePriority(priority)
+		if err != nil {
+			v.AddError("priority", "must be one of low, normal, high or urgent")
+		}
+		input.Priority = &p
+	}
+	// Get the page information. A cursor from an earlier response carries
+	// its sort order, which then need not be repeated
 	input.Filters.Page = app.readInt(qs, "page", 1, v)
 	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	defaultSort := "id"
+	if cursor := app.readString(qs, "cursor", ""); cursor != "" {
+		after, err := data.DecodeCursor(cursor, app.config.cursor.key)
+		if err != nil {
+			v.AddError("cursor", "is invalid")
+		} else {
+			input.Filters.After = after
+			defaultSort = after.Sort
+		}
+	}
 	// Get the sort information
-	input.Filters.Sort = app.readString(qs, "sort", "id")
+	input.Filters.Sort = app.readString(qs, "sort", defaultSort)
 	// Specific the allowed sort values
-	input.Filters.SortList = []string{"id", "item", "description", "-id", "-item", "-description"}
+	input.Filters.SortList = []string{"id", "item", "description", "due_at", "priority", "-id", "-item", "-description", "-due_at", "-priority"}
 	// Check for validation errors
 	if data.ValidateFilters(v, input.Filters); !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 	// Get a listing of all schools
-	lists, metadata,err := app.models.Todo.GetAll(input.Item, input.Descript, input.Filters)
+	lists, metadata,err := app.models.Todo.GetAll(r.Context(), app.todoOwnerID(r), input.TodoSearch, input.Filters)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
+	// Hand back the cursor for the following page, signed so that clients
+	// cannot make up their own
+	metadata.Cursor = qs.Get("cursor")
+	if metadata.Next != nil {
+		metadata.NextCursor = metadata.Next.Encode(app.config.cursor.key)
+	}
 	// Send a JSON response containg all the schools
 	err = app.writeJSON(w, http.StatusOK, envelope{"todo": lists, "metadata": metadata}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
-}
\ No newline at end of file
+}
+
+// completeTodoHandler for the "POST /v1/list/:id/complete" endpoint
+func (app *application) completeTodoHandler(w http.ResponseWriter, r *http.Request) {
+	app.setTodoCompletion(w, r, true)
+}
+
+// reopenTodoHandler for the "POST /v1/list/:id/reopen" endpoint
+func (app *application) reopenTodoHandler(w http.ResponseWriter, r *http.Request) {
+	app.setTodoCompletion(w, r, false)
+}
+
+// setTodoCompletion() marks a todo as completed or open. Repeating the
+// action is harmless and leaves the original completed_at untouched
+func (app *application) setTodoCompletion(w http.ResponseWriter, r *http.Request, completed bool) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	// Fetch the orginal record from the database
+	todo, err := app.models.Todo.Get(r.Context(), id, app.todoOwnerID(r))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	if todo.Completed != completed {
+		if completed {
+			todo.Complete()
+		} else {
+			todo.Reopen()
+		}
+		err = app.models.Todo.Update(r.Context(), todo)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrEditConflict):
+				app.editConflictResponse(w, r)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"todo": todo}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// optionalTime is a PATCH field which tells a missing value, where Set is
+// false, from an explicit null, where Set is true and Time is nil
+type optionalTime struct {
+	Set  bool
+	Time *time.Time
+}
+
+// UnmarshalJSON() is only called when the field is present, null included
+func (t *optionalTime) UnmarshalJSON(b []byte) error {
+	t.Set = true
+	return json.Unmarshal(b, &t.Time)
+}
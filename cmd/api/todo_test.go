@@ -0,0 +1,184 @@
+// Filename: cmd/api/todo_test.go
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"Quiz3.zioncastillo.net/internal/data"
+	"Quiz3.zioncastillo.net/internal/jsonlog"
+	"github.com/julienschmidt/httprouter"
+)
+
+// newTestApplication() returns an application which keeps todos in a
+// MemoryTodoStore. Only handlers which use nothing but the todo store can
+// be served by it
+func newTestApplication() *application {
+	app := &application{
+		logger: jsonlog.New(io.Discard, jsonlog.LevelOff),
+		models: data.Models{Todo: data.NewMemoryTodoStore()},
+	}
+	app.config.cursor.key = []byte("test key")
+	return app
+}
+
+// testUser is a signed in user as authenticate() and requirePermission()
+// would leave them in the request context
+type testUser struct {
+	user        *data.User
+	permissions data.Permissions
+}
+
+// todoTestRoutes() serves the todo handlers for the given user. The list
+// routes call the list-independent halves of their handlers, as looking
+// the list up needs the database
+func (app *application) todoTestRoutes(u testUser) http.Handler {
+	router := httprouter.New()
+	router.HandlerFunc(http.MethodGet, "/v1/list/:id", app.showTodoHandler)
+	router.HandlerFunc(http.MethodPatch, "/v1/list/:id", app.updateTodoHandler)
+	router.HandlerFunc(http.MethodDelete, "/v1/list/:id", app.deleteTodoHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/lists/:id/items", func(w http.ResponseWriter, r *http.Request) {
+		id, _ := app.readIDParam(r)
+		app.createTodo(w, r, id)
+	})
+	router.HandlerFunc(http.MethodGet, "/v1/lists/:id/items", func(w http.ResponseWriter, r *http.Request) {
+		id, _ := app.readIDParam(r)
+		app.listTodos(w, r, id)
+	})
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r = app.contextSetUser(r, u.user)
+		r = app.contextSetPermissions(r, u.permissions)
+		router.ServeHTTP(w, r)
+	})
+}
+
+// do() sends one request and decodes the JSON response
+func (app *application) do(t *testing.T, u testUser, method, path, body string, header http.Header) (int, map[string]json.RawMessage) {
+	t.Helper()
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	for key, values := range header {
+		r.Header[key] = values
+	}
+	rr := httptest.NewRecorder()
+	app.todoTestRoutes(u).ServeHTTP(rr, r)
+
+	var response map[string]json.RawMessage
+	err := json.Unmarshal(rr.Body.Bytes(), &response)
+	if err != nil {
+		t.Fatalf("%s %s: decoding %q: %v", method, path, rr.Body.String(), err)
+	}
+	return rr.Code, response
+}
+
+func TestTodoHandlers(t *testing.T) {
+	app := newTestApplication()
+	alice := testUser{&data.User{ID: 1, Activated: true}, data.Permissions{data.PermissionTodosRead, data.PermissionTodosWrite}}
+	bob := testUser{&data.User{ID: 2, Activated: true}, data.Permissions{data.PermissionTodosRead, data.PermissionTodosWrite}}
+	admin := testUser{&data.User{ID: 3, Activated: true}, data.Permissions{data.PermissionTodosAdmin}}
+
+	status, response := app.do(t, alice, http.MethodPost, "/v1/lists/1/items", `{"item": "buy milk", "due_at": "2030-01-01T00:00:00Z"}`, nil)
+	if status != http.StatusCreated {
+		t.Fatalf("creating: got status %d, want %d", status, http.StatusCreated)
+	}
+	var created data.Todo
+	err := json.Unmarshal(response["item"], &created)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := fmt.Sprintf("/v1/list/%d", created.ID)
+
+	tests := []struct {
+		name   string
+		user   testUser
+		method string
+		path   string
+		body   string
+		header http.Header
+		want   int
+	}{
+		{"owner reads", alice, http.MethodGet, path, "", nil, http.StatusOK},
+		{"other user reads", bob, http.MethodGet, path, "", nil, http.StatusNotFound},
+		{"admin reads", admin, http.MethodGet, path, "", nil, http.StatusOK},
+		{"invalid id", alice, http.MethodGet, "/v1/list/abc", "", nil, http.StatusNotFound},
+		{"other user updates", bob, http.MethodPatch, path, `{"item": "sell milk"}`, nil, http.StatusNotFound},
+		{"invalid expected version", alice, http.MethodPatch, path, `{"item": "buy oat milk"}`, http.Header{"X-Expected-Version": {"one"}}, http.StatusBadRequest},
+		{"stale expected version", alice, http.MethodPatch, path, `{"item": "buy oat milk"}`, http.Header{"X-Expected-Version": {"5"}}, http.StatusConflict},
+		{"invalid update", alice, http.MethodPatch, path, `{"item": ""}`, nil, http.StatusUnprocessableEntity},
+		{"owner updates", alice, http.MethodPatch, path, `{"item": "buy oat milk"}`, http.Header{"X-Expected-Version": {"1"}}, http.StatusOK},
+		{"invalid filter", alice, http.MethodGet, "/v1/lists/1/items?sort=owner", "", nil, http.StatusUnprocessableEntity},
+		{"invalid cursor", alice, http.MethodGet, "/v1/lists/1/items?cursor=abc", "", nil, http.StatusUnprocessableEntity},
+		{"update without due date", alice, http.MethodPatch, path, `{"priority": "high"}`, nil, http.StatusOK},
+		{"clear due date", alice, http.MethodPatch, path, `{"due_at": null}`, nil, http.StatusOK},
+		{"invalid due date", alice, http.MethodPatch, path, `{"due_at": "tomorrow"}`, nil, http.StatusBadRequest},
+		{"other user deletes", bob, http.MethodDelete, path, "", nil, http.StatusNotFound},
+		{"owner deletes", alice, http.MethodDelete, path, "", nil, http.StatusOK},
+		{"deleted", alice, http.MethodGet, path, "", nil, http.StatusNotFound},
+	}
+	for _, test := range tests {
+		status, response := app.do(t, test.user, test.method, test.path, test.body, test.header)
+		if status != test.want {
+			t.Errorf("%s: got status %d, want %d: %s", test.name, status, test.want, response["error"])
+		}
+		var updated data.Todo
+		switch test.name {
+		case "owner updates", "update without due date", "clear due date":
+			err := json.Unmarshal(response["todo"], &updated)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		switch {
+		case test.name == "owner updates" && (updated.Item != "buy oat milk" || updated.Version != 2 || updated.DueAt == nil),
+			test.name == "update without due date" && updated.DueAt == nil,
+			test.name == "clear due date" && updated.DueAt != nil:
+			t.Errorf("%s: got %+v", test.name, updated)
+		}
+	}
+}
+
+// Listing goes through the store's filters and paging, and hands out a
+// cursor which leads to the rest of the list
+func TestListTodosHandler(t *testing.T) {
+	app := newTestApplication()
+	alice := testUser{&data.User{ID: 1, Activated: true}, data.Permissions{data.PermissionTodosRead, data.PermissionTodosWrite}}
+	bob := testUser{&data.User{ID: 2, Activated: true}, data.Permissions{data.PermissionTodosRead, data.PermissionTodosWrite}}
+	for _, item := range []string{"buy milk", "call mom", "buy bread"} {
+		status, _ := app.do(t, alice, http.MethodPost, "/v1/lists/1/items", fmt.Sprintf(`{"item": %q}`, item), nil)
+		if status != http.StatusCreated {
+			t.Fatalf("creating %q: got status %d", item, status)
+		}
+	}
+	app.do(t, bob, http.MethodPost, "/v1/lists/1/items", `{"item": "buy cheese"}`, nil)
+
+	var items []string
+	path := "/v1/lists/1/items?item=buy&sort=item&page_size=1"
+	for len(items) < 10 {
+		status, response := app.do(t, alice, http.MethodGet, path, "", nil)
+		if status != http.StatusOK {
+			t.Fatalf("GET %s: got status %d: %s", path, status, response["error"])
+		}
+		var page []data.Todo
+		var metadata data.Metadata
+		if err := json.Unmarshal(response["todo"], &page); err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal(response["metadata"], &metadata); err != nil {
+			t.Fatal(err)
+		}
+		for _, todo := range page {
+			items = append(items, todo.Item)
+		}
+		if metadata.NextCursor == "" {
+			break
+		}
+		path = "/v1/lists/1/items?item=buy&page_size=1&cursor=" + metadata.NextCursor
+	}
+	if got, want := strings.Join(items, ", "), "buy bread, buy milk"; got != want {
+		t.Errorf("got items %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,65 @@
+// Filename: cmd/api/users_test.go
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Passwords are checked before they reach bcrypt, so a rejected sign up
+// never touches the database
+func TestRegisterUserHandlerValidation(t *testing.T) {
+	app := newTestApplication()
+	tests := []struct {
+		name string
+		body string
+		want map[string]string
+	}{
+		{
+			name: "password too long",
+			body: `{"name": "Alice", "email": "alice@example.com", "password": "` + strings.Repeat("a", 73) + `"}`,
+			want: map[string]string{"password": "must not be more than 72 bytes long"},
+		},
+		{
+			name: "password too short",
+			body: `{"name": "", "email": "alice", "password": "secret"}`,
+			want: map[string]string{
+				"name":     "must be provided",
+				"email":    "must be a valid email address",
+				"password": "must be at least 8 bytes long",
+			},
+		},
+		{
+			name: "invalid name",
+			body: `{"name": "", "email": "alice@example.com", "password": "pa55word"}`,
+			want: map[string]string{"name": "must be provided"},
+		},
+	}
+	for _, test := range tests {
+		rr := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/v1/users", strings.NewReader(test.body))
+		app.registerUserHandler(rr, r)
+		if rr.Code != http.StatusUnprocessableEntity {
+			t.Errorf("%s: got status %d, want %d: %s", test.name, rr.Code, http.StatusUnprocessableEntity, rr.Body)
+			continue
+		}
+		var response struct {
+			Error map[string]string `json:"error"`
+		}
+		err := json.Unmarshal(rr.Body.Bytes(), &response)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(response.Error) != len(test.want) {
+			t.Errorf("%s: got errors %v, want %v", test.name, response.Error, test.want)
+		}
+		for key, message := range test.want {
+			if response.Error[key] != message {
+				t.Errorf("%s: got %s error %q, want %q", test.name, key, response.Error[key], message)
+			}
+		}
+	}
+}
@@ -1,8 +1,17 @@
 module Quiz3.zioncastillo.net
 
-go 1.19
+go 1.21
 
 require (
-	github.com/julienschmidt/httprouter v1.3.0 // indirect
-	github.com/lib/pq v1.10.7 // indirect
+	github.com/go-mail/mail/v2 v2.3.0
+	github.com/julienschmidt/httprouter v1.3.0
+	github.com/lib/pq v1.10.7
+	github.com/mattn/go-sqlite3 v1.14.22
+	golang.org/x/crypto v0.14.0
+	golang.org/x/time v0.5.0
+)
+
+require (
+	gopkg.in/alexcesaro/quotedprintable.v3 v3.0.0-20150716171945-2caba252f4dc // indirect
+	gopkg.in/mail.v2 v2.3.1 // indirect
 )
@@ -0,0 +1,150 @@
+// Filename: internal/data/cursor.go
+
+package data
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrInvalidCursor is returned for a cursor that was altered, truncated or
+// signed with another key
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// A Cursor marks the last todo of a page by its sort key and id, so the
+// next page can start right after it however many rows were added or
+// removed in the meantime. Only the field for the Sort column is set
+type Cursor struct {
+	Sort string     `json:"s"`
+	ID   int64      `json:"i"`
+	Text string     `json:"t,omitempty"` // item or description
+	Int  int64      `json:"n,omitempty"` // priority
+	Time *time.Time `json:"d,omitempty"` // due_at, nil when the todo has none
+}
+
+// The NewTodoCursor() function returns the cursor which follows todo in
+// the given sort order
+func NewTodoCursor(todo *Todo, sort string) Cursor {
+	c := Cursor{Sort: sort, ID: todo.ID}
+	switch strings.TrimPrefix(sort, "-") {
+	case "item":
+		c.Text = todo.Item
+	case "description":
+		c.Text = todo.Description
+	case "priority":
+		c.Int = int64(todo.Priority)
+	case "due_at":
+		if todo.DueAt != nil {
+			t := todo.DueAt.UTC()
+			c.Time = &t
+		}
+	}
+	return c
+}
+
+// Encode() returns the cursor as an opaque token, base64 JSON followed by
+// its HMAC-SHA256 signature, so clients cannot forge a position
+func (c Cursor) Encode(key []byte) string {
+	payload, _ := json.Marshal(c)
+	mac := hmac.New(sha256.New, key)
+	mac.Write(payload)
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// The DecodeCursor() function checks the signature on a token made by
+// Encode() and returns the cursor in it
+func DecodeCursor(token string, key []byte) (*Cursor, error) {
+	encodedPayload, encodedSignature, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, ErrInvalidCursor
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(encodedSignature)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(payload)
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return nil, ErrInvalidCursor
+	}
+	var c Cursor
+	err = json.Unmarshal(payload, &c)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// keyset() returns the condition which selects the rows after f.After and
+// its arguments, numbered from n with prefix: "$" for PostgreSQL and "?"
+// for SQLite. It follows the order used by GetAll(), which is the sort
+// column, with NULLs last ascending and first descending, then id ascending
+func (f Filters) keyset(prefix string, n int) (string, []interface{}) {
+	c := f.After
+	if c == nil {
+		return "TRUE", nil
+	}
+	value, id := fmt.Sprintf("%s%d", prefix, n), fmt.Sprintf("%s%d", prefix, n+1)
+	column, desc := f.sortColumn(), f.sortOrder() == "DESC"
+	cmp := ">"
+	if desc {
+		cmp = "<"
+	}
+
+	var arg interface{}
+	switch column {
+	case "id":
+		return fmt.Sprintf("id %s %s", cmp, value), []interface{}{c.ID}
+	case "item", "description":
+		arg = c.Text
+	case "priority":
+		arg = c.Int
+	case "due_at":
+		switch {
+		case c.Time == nil && desc:
+			// Past the todos without a due date, which come first
+			return fmt.Sprintf("(due_at IS NOT NULL OR id > %s)", value), []interface{}{c.ID}
+		case c.Time == nil:
+			return fmt.Sprintf("(due_at IS NULL AND id > %s)", value), []interface{}{c.ID}
+		case !desc:
+			// The todos without a due date still follow
+			return fmt.Sprintf("(due_at > %[1]s OR due_at IS NULL OR (due_at = %[1]s AND id > %[2]s))", value, id),
+				[]interface{}{*c.Time, c.ID}
+		}
+		arg = *c.Time
+	}
+	return fmt.Sprintf("(%[1]s %[2]s %[3]s OR (%[1]s = %[3]s AND id > %[4]s))", column, cmp, value, id),
+		[]interface{}{arg, c.ID}
+}
+
+// todoMetadata() describes a page of todos. count is COUNT(*) OVER(): every
+// match for page-based requests, or the matches after the cursor. When more
+// todos follow, Next holds the cursor for them
+func todoMetadata(todos []*Todo, count int, f Filters) Metadata {
+	var (
+		metadata Metadata
+		more     bool
+	)
+	if f.After == nil {
+		metadata = calculateMetadata(count, f.Page, f.PageSize)
+		more = f.Page*f.PageSize < count
+	} else {
+		metadata = Metadata{PageSize: f.PageSize, RemainingRecords: count}
+		more = count > len(todos)
+	}
+	if more && len(todos) > 0 {
+		next := NewTodoCursor(todos[len(todos)-1], f.Sort)
+		metadata.Next = &next
+	}
+	return metadata
+}
@@ -0,0 +1,53 @@
+// Filename: internal/data/cursor_test.go
+
+package data
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecodeCursor(t *testing.T) {
+	key := []byte("test key")
+	cursor := NewTodoCursor(&Todo{ID: 7, DueAt: date("2030-06-01")}, "-due_at")
+	token := cursor.Encode(key)
+	payload, signature, _ := strings.Cut(token, ".")
+
+	// A cursor for another position, sent with the original signature
+	forged, _ := json.Marshal(Cursor{Sort: "-due_at", ID: 1})
+	forgedToken := base64.RawURLEncoding.EncodeToString(forged) + "." + signature
+
+	got, err := DecodeCursor(token, key)
+	if err != nil {
+		t.Fatalf("DecodeCursor(): %v", err)
+	}
+	if !reflect.DeepEqual(*got, cursor) {
+		t.Errorf("got %+v, want %+v", *got, cursor)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		key   []byte
+	}{
+		{"tampered payload", forgedToken, key},
+		{"tampered signature", payload + "." + strings.Repeat("A", len(signature)), key},
+		{"other key", token, []byte("other key")},
+		{"no signature", payload, key},
+		{"truncated", token[:len(token)-2], key},
+		{"not base64", "!!!." + signature, key},
+		{"empty", "", key},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := DecodeCursor(test.token, test.key)
+			if !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("got error %v, want %v", err, ErrInvalidCursor)
+			}
+		})
+	}
+}
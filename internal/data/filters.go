@@ -14,6 +14,9 @@ type Filters struct {
 	PageSize int
 	Sort     string
 	SortList []string
+	// After switches GetAll() from pages to keyset pagination, starting
+	// after the cursor. Only the todo listing supports it
+	After *Cursor
 }
 
 func ValidateFilters(v *validator.Validator, f Filters) {
@@ -24,6 +27,10 @@ func ValidateFilters(v *validator.Validator, f Filters) {
 	v.Check(f.PageSize <= 100, "page_size", "must be a maximum of 100")
 	// Check that the sort parameter matches a value in the acceptable sort list
 	v.Check(validator.In(f.Sort, f.SortList...), "sort", "invalid sort value")
+	if f.After != nil {
+		v.Check(f.Page == 1, "page", "must not be used with a cursor")
+		v.Check(f.After.Sort == f.Sort, "cursor", "does not match the sort order")
+	}
 }
 
 // The sortColumn() method safety extracts the sort field query parameter
@@ -49,8 +56,11 @@ func (f Filters) limit() int {
 	return f.PageSize
 }
 
-// The offset() method calculates the OFFSET
+// The offset() method calculates the OFFSET. A cursor replaces it
 func (f Filters) offset() int {
+	if f.After != nil {
+		return 0
+	}
 	return (f.Page - 1) * f.PageSize
 }
 
@@ -61,6 +71,13 @@ type Metadata struct {
 	FirstPage    int `json:"first_page,omitempty"`
 	LastPage     int `json:"last_page,omitempty"`
 	TotalRecords int `json:"total_records,omitempty"`
+	// Keyset pagination. RemainingRecords counts from the cursor onwards
+	RemainingRecords int    `json:"remaining_records,omitempty"`
+	Cursor           string `json:"cursor,omitempty"`
+	NextCursor       string `json:"next_cursor,omitempty"`
+	// Next is the unsigned form of NextCursor. Handlers sign it, as only
+	// they hold the key
+	Next *Cursor `json:"-"`
 }
 
 // The calculateMetadata() function computes the values for the Metadata fields
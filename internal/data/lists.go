@@ -0,0 +1,264 @@
+// Filename: internal/data/lists.go
+
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"Quiz3.zioncastillo.net/internal/validator"
+)
+
+// ErrDefaultList is returned when trying to delete the default list
+var ErrDefaultList = errors.New("default list")
+
+// A List is a named collection of todo items
+type List struct {
+	ID             int64     `json:"id"`
+	CreatedAt      time.Time `json:"-"`
+	UserID         int64     `json:"-"`
+	Name           string    `json:"name"`
+	Description    string    `json:"description"`
+	IsDefault      bool      `json:"is_default"`
+	ItemCount      int       `json:"item_count"`
+	CompletedCount int       `json:"completed_count"`
+	Version        int32     `json:"version"`
+}
+
+func ValidateList(v *validator.Validator, list *List) {
+	// Use the Check() method to execute our validation checks
+	v.Check(list.Name != "", "name", "must be provided")
+	v.Check(len(list.Name) <= 200, "name", "must not be more than 200 bytes long")
+	v.Check(len(list.Description) <= 2000, "description", "must not be more than 2000 bytes long")
+}
+
+// Define a ListModel which wraps a sql.DB connection pool. The SQL is
+// shared by both backends except for the name search in GetAll(). SQLite
+// numbers $N parameters in the order they first appear, so each query has
+// to use them in that order
+type ListModel struct {
+	DB     *sql.DB
+	sqlite bool
+}
+
+// Insert() creates a new, empty list owned by list.UserID
+func (m ListModel) Insert(list *List) error {
+	query := `
+		INSERT INTO lists (user_id, name, description)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at, version
+	`
+
+	args := []interface{}{
+		list.UserID,
+		list.Name,
+		list.Description,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&list.ID, &list.CreatedAt, &list.Version)
+}
+
+// Get() returns a specific list the user may see, which is one of their
+// own or a shared list. The item counts only include the user's items. A
+// userID of 0 matches any list and counts every item
+func (m ListModel) Get(id int64, userID int64) (*List, error) {
+	// Ensure that there is a valid id
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+	return m.getWhere("l.id = $2", userID, id)
+}
+
+// GetDefault() returns the list that backs the /v1/list endpoints, with
+// the user's item counts
+func (m ListModel) GetDefault(userID int64) (*List, error) {
+	return m.getWhere("l.is_default", userID)
+}
+
+// getWhere() runs the single list query. The user id is always $1
+func (m ListModel) getWhere(condition string, userID int64, args ...interface{}) (*List, error) {
+	// Create the query
+	query := fmt.Sprintf(`
+		SELECT l.id, l.created_at, COALESCE(l.user_id, 0), l.name, l.description, l.is_default,
+			COUNT(t.id), COUNT(t.id) FILTER (WHERE t.completed), l.version
+		FROM lists l
+		LEFT JOIN todolist t ON t.list_id = l.id AND (t.user_id = $1 OR $1 = 0)
+		WHERE %s
+		AND (l.user_id = $1 OR l.user_id IS NULL OR $1 = 0)
+		GROUP BY l.id
+	`, condition)
+	var list List
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// Execute the query using QueryRow()
+	err := m.DB.QueryRowContext(ctx, query, append([]interface{}{userID}, args...)...).Scan(
+		&list.ID,
+		&list.CreatedAt,
+		&list.UserID,
+		&list.Name,
+		&list.Description,
+		&list.IsDefault,
+		&list.ItemCount,
+		&list.CompletedCount,
+		&list.Version,
+	)
+	// Handle any errors
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &list, nil
+}
+
+// Update() renames a list, using the version number as an optimistic lock.
+// Only the owner may rename a list, and shared lists can only be renamed
+// with a userID of 0
+func (m ListModel) Update(list *List, userID int64) error {
+	query := `
+		UPDATE lists
+		SET name = $1, description = $2, version = version + 1
+		WHERE id = $3 AND version = $4 AND (user_id = $5 OR $5 = 0)
+		RETURNING version
+	`
+
+	args := []interface{}{
+		list.Name,
+		list.Description,
+		list.ID,
+		list.Version,
+		userID,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// Check for an edit conflict
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&list.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete() removes a list and, through the foreign key, all of its items.
+// The default list cannot be deleted
+func (m ListModel) Delete(id int64) error {
+	// Ensure that there is a valid id
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+	query := `
+		DELETE FROM lists
+		WHERE id = $1
+		RETURNING is_default
+	`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// Run the delete in a transaction so that deleting the default list
+	// can be rolled back
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var isDefault bool
+	err = tx.QueryRowContext(ctx, query, id).Scan(&isDefault)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+	if isDefault {
+		return ErrDefaultList
+	}
+	return tx.Commit()
+}
+
+// GetAll() returns a page of the lists the user may see whose name matches
+// the search term. As with Get() the counts only include the user's items
+func (m ListModel) GetAll(userID int64, name string, filters Filters) ([]*List, Metadata, error) {
+	nameMatch := `to_tsvector('simple', l.name) @@ plainto_tsquery('simple', $2)`
+	// The empty term is tested first as FTS5 rejects an empty MATCH
+	if m.sqlite {
+		nameMatch = `l.id IN (SELECT rowid FROM lists_fts WHERE lists_fts MATCH $2)`
+		name = ftsMatch("name", name)
+	}
+	// todolist has an id column too, and SQLite will not resolve a bare id
+	// to the result column
+	column := filters.sortColumn()
+	if column != "item_count" {
+		column = "l." + column
+	}
+	// Construct the query
+	query := fmt.Sprintf(`
+		SELECT COUNT(*) OVER(), l.id, l.created_at, COALESCE(l.user_id, 0), l.name, l.description, l.is_default,
+			COUNT(t.id) AS item_count, COUNT(t.id) FILTER (WHERE t.completed), l.version
+		FROM lists l
+		LEFT JOIN todolist t ON t.list_id = l.id AND (t.user_id = $1 OR $1 = 0)
+		WHERE ($2 = '' OR %s)
+		AND (l.user_id = $1 OR l.user_id IS NULL OR $1 = 0)
+		GROUP BY l.id
+		ORDER BY %s %s, l.id ASC
+		LIMIT $3 OFFSET $4`, nameMatch, column, filters.sortOrder())
+
+	// Create a 3-second-timout context
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	// Execute the query
+	rows, err := m.DB.QueryContext(ctx, query, userID, name, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	// Close the resultset
+	defer rows.Close()
+	totalRecords := 0
+	lists := []*List{}
+	// Iterate over the rows in the resultset
+	for rows.Next() {
+		var list List
+		err := rows.Scan(
+			&totalRecords,
+			&list.ID,
+			&list.CreatedAt,
+			&list.UserID,
+			&list.Name,
+			&list.Description,
+			&list.IsDefault,
+			&list.ItemCount,
+			&list.CompletedCount,
+			&list.Version,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		lists = append(lists, &list)
+	}
+	// Check for errors after looping through the resultset
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return lists, metadata, nil
+}
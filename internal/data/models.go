@@ -3,8 +3,10 @@
 package data
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"time"
 )
 
 var (
@@ -12,14 +14,53 @@ var (
 	ErrEditConflict   = errors.New("edit conflict")
 )
 
+// TodoStore is the set of todo operations the handlers rely on. TodoModel
+// keeps todos in PostgreSQL and MemoryTodoStore keeps them in memory. A
+// userID of 0 matches todos from any owner
+type TodoStore interface {
+	Insert(ctx context.Context, todo *Todo) error
+	Get(ctx context.Context, id int64, userID int64) (*Todo, error)
+	Update(ctx context.Context, todo *Todo) error
+	Delete(ctx context.Context, id int64, userID int64) error
+	GetAll(ctx context.Context, userID int64, search TodoSearch, filters Filters) ([]*Todo, Metadata, error)
+}
+
+// dbtx is what the models need to run a query, so that the same code can
+// run on the connection pool or inside a transaction
+type dbtx interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
 // A wrapper for our data models
 type Models struct {
-	Todo TodoModel
+	Todo       TodoStore
+	List       ListModel
+	User       UserModel
+	Token      TokenModel
+	Permission PermissionModel
+}
+
+// NewModels() allows us to create a new Models. queryTimeout bounds each
+// todo query on top of the caller's context
+func NewModels(db *sql.DB, queryTimeout time.Duration) Models {
+	return Models{
+		Todo:       TodoModel{DB: db, QueryTimeout: queryTimeout},
+		List:       ListModel{DB: db},
+		User:       UserModel{DB: db},
+		Token:      TokenModel{DB: db},
+		Permission: PermissionModel{DB: db},
+	}
 }
 
-// NewModels() allows us to create a new Models
-func NewModels(db *sql.DB) Models {
+// NewSQLiteModels() returns the models for a database created from the
+// SQLite migration set
+func NewSQLiteModels(db *sql.DB, queryTimeout time.Duration) Models {
 	return Models{
-		Todo: TodoModel{DB: db},
+		Todo:       SQLiteTodoStore{DB: db, QueryTimeout: queryTimeout},
+		List:       ListModel{DB: db, sqlite: true},
+		User:       UserModel{DB: db},
+		Token:      TokenModel{DB: db},
+		Permission: PermissionModel{DB: db},
 	}
 }
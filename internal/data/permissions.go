@@ -0,0 +1,97 @@
+// Filename: internal/data/permissions.go
+
+package data
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Permission codes
+const (
+	PermissionTodosRead  = "todos:read"
+	PermissionTodosWrite = "todos:write"
+	PermissionTodosAdmin = "todos:admin"
+)
+
+// Permissions holds the permission codes granted to a user
+type Permissions []string
+
+// Include() checks if a permission code is in the slice
+func (p Permissions) Include(code string) bool {
+	for i := range p {
+		if code == p[i] {
+			return true
+		}
+	}
+	return false
+}
+
+// Define a PermissionModel which wraps a sql.DB connection pool
+type PermissionModel struct {
+	DB *sql.DB
+}
+
+// GetAllForUser() returns every permission code granted to the user
+func (m PermissionModel) GetAllForUser(userID int64) (Permissions, error) {
+	query := `
+		SELECT permissions.code
+		FROM permissions
+		INNER JOIN users_permissions ON users_permissions.permission_id = permissions.id
+		INNER JOIN users ON users_permissions.user_id = users.id
+		WHERE users.id = $1
+	`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var permissions Permissions
+	for rows.Next() {
+		var permission string
+		err := rows.Scan(&permission)
+		if err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, permission)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return permissions, nil
+}
+
+// AddForUser() grants the given permission codes to the user. The codes
+// are listed with one placeholder each, which PostgreSQL and SQLite both
+// understand
+func (m PermissionModel) AddForUser(userID int64, codes ...string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return addPermissions(ctx, m.DB, userID, codes...)
+}
+
+func addPermissions(ctx context.Context, q dbtx, userID int64, codes ...string) error {
+	if len(codes) == 0 {
+		return nil
+	}
+	placeholders := make([]string, len(codes))
+	args := []interface{}{userID}
+	for i, code := range codes {
+		placeholders[i] = fmt.Sprintf("$%d", i+2)
+		args = append(args, code)
+	}
+	query := fmt.Sprintf(`
+		INSERT INTO users_permissions
+		SELECT $1, permissions.id FROM permissions WHERE permissions.code IN (%s)
+	`, strings.Join(placeholders, ", "))
+	_, err := q.ExecContext(ctx, query, args...)
+	return err
+}
@@ -0,0 +1,79 @@
+// Filename: internal/data/priority.go
+
+package data
+
+import (
+	"errors"
+	"strconv"
+)
+
+// ErrInvalidPriorityFormat is returned when a priority is not one of the
+// known names
+var ErrInvalidPriorityFormat = errors.New("invalid priority format")
+
+// Priority is stored as a small integer so that sorting by it follows
+// urgency rather than the alphabetical order of the names
+type Priority int16
+
+const (
+	PriorityLow    Priority = iota + 1 // value is 1
+	PriorityNormal                     // value is 2
+	PriorityHigh                       // value is 3
+	PriorityUrgent                     // value is 4
+)
+
+// The priority as a human-readeable friendly format
+func (p Priority) String() string {
+	switch p {
+	case PriorityLow:
+		return "low"
+	case PriorityNormal:
+		return "normal"
+	case PriorityHigh:
+		return "high"
+	case PriorityUrgent:
+		return "urgent"
+	default:
+		return ""
+	}
+}
+
+// Valid() reports whether p is one of the known priorities
+func (p Priority) Valid() bool {
+	return p >= PriorityLow && p <= PriorityUrgent
+}
+
+// ParsePriority() converts a priority name into a Priority
+func ParsePriority(s string) (Priority, error) {
+	switch s {
+	case "low":
+		return PriorityLow, nil
+	case "normal":
+		return PriorityNormal, nil
+	case "high":
+		return PriorityHigh, nil
+	case "urgent":
+		return PriorityUrgent, nil
+	default:
+		return 0, ErrInvalidPriorityFormat
+	}
+}
+
+// MarshalJSON() encodes the priority as its name
+func (p Priority) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(p.String())), nil
+}
+
+// UnmarshalJSON() decodes a priority name such as "high"
+func (p *Priority) UnmarshalJSON(jsonValue []byte) error {
+	unquotedJSONValue, err := strconv.Unquote(string(jsonValue))
+	if err != nil {
+		return ErrInvalidPriorityFormat
+	}
+	priority, err := ParsePriority(unquotedJSONValue)
+	if err != nil {
+		return err
+	}
+	*p = priority
+	return nil
+}
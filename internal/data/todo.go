@@ -14,10 +14,17 @@ import (
 )
 
 type Todo struct {
-	ID           int64     `json:"id"`
-	CreatedAt    time.Time `json:"-"`
-	Item         string    `json:"item"`
-	Description  string    `json:"description"`
+	ID           int64      `json:"id"`
+	CreatedAt    time.Time  `json:"-"`
+	ListID       int64      `json:"list_id"`
+	UserID       int64      `json:"-"`
+	Item         string     `json:"item"`
+	Description  string     `json:"description"`
+	Completed    bool       `json:"completed"`
+	CompletedAt  *time.Time `json:"completed_at,omitempty"`
+	DueAt        *time.Time `json:"due_at,omitempty"`
+	Priority     Priority   `json:"priority"`
+	Version      int32      `json:"version"`
 }
 
 func ValidateItem(v *validator.Validator, todo *Todo) {
@@ -25,56 +32,129 @@ func ValidateItem(v *validator.Validator, todo *Todo) {
 	v.Check(todo.Item != "", "name", "must be provided")
 	v.Check(len(todo.Item) <= 200, "Item", "must not be more than 200 bytes long")
 	v.Check(len(todo.Description) <= 2000, "level", "must not be more than 2000 bytes long")
+	// A completed todo must record when it was completed, an open one must not
+	v.Check(!todo.Completed || todo.CompletedAt != nil, "completed_at", "must be provided for a completed item")
+	v.Check(todo.Completed || todo.CompletedAt == nil, "completed_at", "must not be set for an open item")
+	v.Check(todo.Priority.Valid(), "priority", "must be one of low, normal, high or urgent")
+	if todo.DueAt != nil {
+		v.Check(todo.DueAt.Year() >= 2000 && todo.DueAt.Year() <= 9999, "due_at", "must be a realistic date")
+	}
+}
+
+// TodoSearch holds the optional criteria used by GetAll() to narrow the
+// listing. Nil pointers, zero ids and empty strings mean "do not filter"
+type TodoSearch struct {
+	ListID      int64
+	Item        string
+	Description string
+	Completed   *bool
+	DueBefore   *time.Time
+	DueAfter    *time.Time
+	Overdue     *bool
+	Priority    *Priority
+}
+
+// Complete() marks the todo as done at the current time
+func (todo *Todo) Complete() {
+	now := time.Now().UTC().Truncate(time.Second)
+	todo.Completed = true
+	todo.CompletedAt = &now
+}
+
+// Reopen() marks the todo as not done
+func (todo *Todo) Reopen() {
+	todo.Completed = false
+	todo.CompletedAt = nil
 }
 
-// Define a TodoModel which wraps a sql.DB connection pool
+// Define a TodoModel which wraps a sql.DB connection pool. Every method
+// takes the caller's context, normally the request's, so a client that goes
+// away cancels its query. QueryTimeout further bounds each query when it is
+// greater than zero
 type TodoModel struct {
-	DB *sql.DB
+	DB           *sql.DB
+	QueryTimeout time.Duration
+}
+
+// queryContext() derives the context a single query runs under
+func (m TodoModel) queryContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if m.QueryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, m.QueryTimeout)
+}
+
+// contextError() reports a query that failed because its context ended as
+// context.Canceled or context.DeadlineExceeded. PostgreSQL itself only
+// says "canceling statement due to user request", which hides the reason
+func contextError(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	if ctxErr := ctx.Err(); ctxErr != nil && !errors.Is(err, ctxErr) {
+		return fmt.Errorf("%w: %v", ctxErr, err)
+	}
+	return err
 }
 
-func (m TodoModel) Insert(todo *Todo) error {
+func (m TodoModel) Insert(ctx context.Context, todo *Todo) error {
 	query := `
-		INSERT INTO todolist (item, description)
-		VALUES ($1, $2)
-		RETURNING id, created_at
+		INSERT INTO todolist (list_id, user_id, item, description, completed, completed_at, due_at, priority)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created_at, version
 	`
 
 	args := []interface{}{
+		todo.ListID,
+		todo.UserID,
 		todo.Item,
 		todo.Description,
+		todo.Completed,
+		todo.CompletedAt,
+		todo.DueAt,
+		todo.Priority,
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-
+	ctx, cancel := m.queryContext(ctx)
 	defer cancel()
 
-	return m.DB.QueryRowContext(ctx, query, args...).Scan(&todo.ID, &todo.CreatedAt)
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&todo.ID, &todo.CreatedAt, &todo.Version)
+	return contextError(ctx, err)
 }
 
-func (m TodoModel) Get(id int64) (*Todo, error) {
+// Get() returns a specific Todo belonging to the user. Todos owned by
+// someone else are reported as ErrRecordNotFound. A userID of 0 matches
+// any owner and is reserved for admins
+func (m TodoModel) Get(ctx context.Context, id int64, userID int64) (*Todo, error) {
 		// Ensure that there is a valid id
 		if id < 1 {
 			return nil, ErrRecordNotFound
 		}
 		// Create the query
 		query := `
-			SELECT id, created_at, item, description
+			SELECT id, created_at, list_id, COALESCE(user_id, 0), item, description, completed, completed_at, due_at, priority, version
 			FROM todolist
-			WHERE id = $1
+			WHERE id = $1 AND (user_id = $2 OR $2 = 0)
 		`
 		// Declare a School variable to hold the returned data
 		var todo Todo
 
-		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-
+		ctx, cancel := m.queryContext(ctx)
 		defer cancel()
 
 		// Execute the query using QueryRow()
-		err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		err := m.DB.QueryRowContext(ctx, query, id, userID).Scan(
 			&todo.ID,
 			&todo.CreatedAt,
+			&todo.ListID,
+			&todo.UserID,
 			&todo.Item,
 			&todo.Description,
+			&todo.Completed,
+			&todo.CompletedAt,
+			&todo.DueAt,
+			&todo.Priority,
+			&todo.Version,
 		)
 		// Handle any errors
 		if err != nil {
@@ -83,7 +163,7 @@ func (m TodoModel) Get(id int64) (*Todo, error) {
 			case errors.Is(err, sql.ErrNoRows):
 				return nil, ErrRecordNotFound
 			default:
-				return nil, err
+				return nil, contextError(ctx, err)
 			}
 		}
 		// Success
@@ -92,31 +172,51 @@ func (m TodoModel) Get(id int64) (*Todo, error) {
 	
 }
 
-// Update() allows us to edit/alter a specific Todo
-func (m TodoModel) Update(todo *Todo) error {
+// Update() allows us to edit/alter a specific Todo. The version number
+// acts as an optimistic lock: if the row was changed since it was read,
+// no row matches and ErrEditConflict is returned. Only the owner's row
+// can match
+func (m TodoModel) Update(ctx context.Context, todo *Todo) error {
 		// Create a query
 		query := `
 		UPDATE todolist
-		SET item = $1, description = $2
-		WHERE id = $3
-		RETURNING id
+		SET item = $1, description = $2, completed = $3, completed_at = $4,
+			due_at = $5, priority = $6, version = version + 1
+		WHERE id = $7 AND (user_id = $8 OR $8 = 0) AND version = $9
+		RETURNING version
 	`
 
 	args := []interface{}{
 		todo.Item,
 		todo.Description,
+		todo.Completed,
+		todo.CompletedAt,
+		todo.DueAt,
+		todo.Priority,
 		todo.ID,
+		todo.UserID,
+		todo.Version,
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	
+	ctx, cancel := m.queryContext(ctx)
 	defer cancel()
 
-	return m.DB.QueryRowContext(ctx, query, args...).Scan(&todo.ID)
+	// Check for an edit conflict
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&todo.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return contextError(ctx, err)
+		}
+	}
+	return nil
 }
 
-// Delete() removes a specific Todo
-func (m TodoModel) Delete(id int64) error {
+// Delete() removes a specific Todo belonging to the user. A userID of 0
+// matches any owner
+func (m TodoModel) Delete(ctx context.Context, id int64, userID int64) error {
 	// Ensure that there is a valid id
 	if id < 1 {
 		return ErrRecordNotFound
@@ -124,16 +224,15 @@ func (m TodoModel) Delete(id int64) error {
 	// Create the delete query
 	query := `
 		DELETE FROM todolist
-		WHERE id = $1
+		WHERE id = $1 AND (user_id = $2 OR $2 = 0)
 	`
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-
+	ctx, cancel := m.queryContext(ctx)
 	defer cancel()
 
 	// Execute the query
-	result, err := m.DB.ExecContext(ctx, query, id)
+	result, err := m.DB.ExecContext(ctx, query, id, userID)
 	if err != nil {
-		return err
+		return contextError(ctx, err)
 	}
 	// Check how many rows were affected by the delete operation. We
 	// call the RowsAffected() method on the result variable
@@ -147,24 +246,49 @@ func (m TodoModel) Delete(id int64) error {
 	}
 	return nil
 }
-func (m TodoModel) GetAll(item string, description string, filters Filters) ([]*Todo, Metadata, error) {
+
+// GetAll() returns a filtered, sorted page of the user's todos. A userID
+// of 0 returns todos from every owner
+func (m TodoModel) GetAll(ctx context.Context, userID int64, search TodoSearch, filters Filters) ([]*Todo, Metadata, error) {
+	// The rows after the cursor, if there is one, take parameters from $12
+	keyset, keysetArgs := filters.keyset("$", 12)
 	// Construct the query
 	query := fmt.Sprintf(`
-		SELECT COUNT(*) OVER(), id, created_at, item, description
+		SELECT COUNT(*) OVER(), id, created_at, list_id, COALESCE(user_id, 0), item, description, completed, completed_at, due_at, priority, version
 		FROM todolist
 		WHERE (to_tsvector('simple', item) @@ plainto_tsquery('simple',$1) OR $1 = '')
 		AND (to_tsvector('simple', description) @@ plainto_tsquery('simple', $2) OR $2 = '')
+		AND (completed = $3 OR $3::boolean IS NULL)
+		AND (due_at < $4 OR $4::timestamptz IS NULL)
+		AND (due_at > $5 OR $5::timestamptz IS NULL)
+		AND ((COALESCE(due_at < NOW(), false) AND NOT completed) = $6 OR $6::boolean IS NULL)
+		AND (priority = $7 OR $7::smallint IS NULL)
+		AND (list_id = $8 OR $8 = 0)
+		AND (user_id = $9 OR $9 = 0)
+		AND %s
 		ORDER BY %s %s, id ASC
-		LIMIT $3 OFFSET $4`, filters.sortColumn(), filters.sortOrder())
+		LIMIT $10 OFFSET $11`, keyset, filters.sortColumn(), filters.sortOrder())
 
-	// Create a 3-second-timout context
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := m.queryContext(ctx)
 	defer cancel()
 	// Execute the query
-	args := []interface{}{item, description, filters.limit(), filters.offset()}
+	args := []interface{}{
+		search.Item,
+		search.Description,
+		search.Completed,
+		search.DueBefore,
+		search.DueAfter,
+		search.Overdue,
+		search.Priority,
+		search.ListID,
+		userID,
+		filters.limit(),
+		filters.offset(),
+	}
+	args = append(args, keysetArgs...)
 	rows, err := m.DB.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, Metadata{}, err
+		return nil, Metadata{}, contextError(ctx, err)
 	}
 	// Close the resultset
 	defer rows.Close()
@@ -179,20 +303,27 @@ func (m TodoModel) GetAll(item string, description string, filters Filters) ([]*
 			&totalRecords,
 			&todo.ID,
 			&todo.CreatedAt,
+			&todo.ListID,
+			&todo.UserID,
 			&todo.Item,
 			&todo.Description,
+			&todo.Completed,
+			&todo.CompletedAt,
+			&todo.DueAt,
+			&todo.Priority,
+			&todo.Version,
 		)
 		if err != nil {
-			return nil, Metadata{}, err
+			return nil, Metadata{}, contextError(ctx, err)
 		}
 		// Add the School to our slice
 		lists = append(lists, &todo)
 	}
 	// Check for errors after looping through the resultset
 	if err = rows.Err(); err != nil {
-		return nil, Metadata{}, err
+		return nil, Metadata{}, contextError(ctx, err)
 	}
-	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	metadata := todoMetadata(lists, totalRecords, filters)
 	// Return the slice of Schools
 	return lists, metadata, nil
 }
\ No newline at end of file
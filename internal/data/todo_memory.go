@@ -0,0 +1,279 @@
+// Filename: internal/data/todo_memory.go
+
+package data
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// MemoryTodoStore is a TodoStore which keeps todos in a map instead of
+// PostgreSQL, so that handlers can be tested without a database. List ids
+// are not checked against the lists table, and the server does not offer it
+// as storage because list counts and cascades happen in SQL
+type MemoryTodoStore struct {
+	mu     sync.RWMutex
+	nextID int64
+	todos  map[int64]*Todo
+}
+
+// The NewMemoryTodoStore() function returns an empty store
+func NewMemoryTodoStore() *MemoryTodoStore {
+	return &MemoryTodoStore{
+		nextID: 1,
+		todos:  make(map[int64]*Todo),
+	}
+}
+
+// Insert() stores a copy of todo and fills in its id, creation time and
+// version the way the database defaults would
+func (m *MemoryTodoStore) Insert(ctx context.Context, todo *Todo) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	todo.ID = m.nextID
+	todo.CreatedAt = time.Now().UTC().Truncate(time.Second)
+	todo.Version = 1
+	m.nextID++
+	m.todos[todo.ID] = todo.clone()
+	return nil
+}
+
+// Get() returns a copy of the todo, following the same ownership rules as
+// TodoModel.Get()
+func (m *MemoryTodoStore) Get(ctx context.Context, id int64, userID int64) (*Todo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	todo, ok := m.todos[id]
+	if !ok || !todo.ownedBy(userID) {
+		return nil, ErrRecordNotFound
+	}
+	return todo.clone(), nil
+}
+
+// Update() replaces the editable fields when the version still matches.
+// Like TodoModel.Update() a missing todo is reported as ErrEditConflict
+func (m *MemoryTodoStore) Update(ctx context.Context, todo *Todo) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.todos[todo.ID]
+	if !ok || !stored.ownedBy(todo.UserID) || stored.Version != todo.Version {
+		return ErrEditConflict
+	}
+	updated := todo.clone()
+	// The list, owner and creation time are never changed by an update
+	updated.ListID = stored.ListID
+	updated.UserID = stored.UserID
+	updated.CreatedAt = stored.CreatedAt
+	updated.Version = stored.Version + 1
+	m.todos[todo.ID] = updated
+	todo.Version = updated.Version
+	return nil
+}
+
+// Delete() removes the todo, following the same ownership rules as Get()
+func (m *MemoryTodoStore) Delete(ctx context.Context, id int64, userID int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	todo, ok := m.todos[id]
+	if !ok || !todo.ownedBy(userID) {
+		return ErrRecordNotFound
+	}
+	delete(m.todos, id)
+	return nil
+}
+
+// GetAll() applies the same filters, ordering and paging as
+// TodoModel.GetAll(). Text searches match whole words, ignoring case, as
+// the 'simple' text search configuration does
+func (m *MemoryTodoStore) GetAll(ctx context.Context, userID int64, search TodoSearch, filters Filters) ([]*Todo, Metadata, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+	column, desc := filters.sortColumn(), filters.sortOrder() == "DESC"
+	now := time.Now()
+
+	m.mu.RLock()
+	matched := []*Todo{}
+	for _, todo := range m.todos {
+		if todo.ownedBy(userID) && search.matches(todo, now) {
+			matched = append(matched, todo.clone())
+		}
+	}
+	m.mu.RUnlock()
+
+	less := func(a, b *Todo) bool {
+		if c := compareTodos(a, b, column, desc); c != 0 {
+			return c < 0
+		}
+		return a.ID < b.ID
+	}
+	sort.Slice(matched, func(i, j int) bool { return less(matched[i], matched[j]) })
+
+	// With a cursor, skip everything up to and including its position
+	if filters.After != nil {
+		at := filters.After.todo()
+		matched = matched[sort.Search(len(matched), func(i int) bool { return less(at, matched[i]) }):]
+	}
+
+	// COUNT(*) OVER() is only seen on rows that are returned, so a page past
+	// the end reports no records at all
+	start := filters.offset()
+	if start >= len(matched) {
+		return []*Todo{}, todoMetadata(nil, 0, filters), nil
+	}
+	end := start + filters.limit()
+	if end > len(matched) {
+		end = len(matched)
+	}
+	return matched[start:end], todoMetadata(matched[start:end], len(matched), filters), nil
+}
+
+// todo() returns a stand-in for the todo the cursor was made from, holding
+// just the fields compareTodos() looks at
+func (c *Cursor) todo() *Todo {
+	return &Todo{
+		ID:          c.ID,
+		Item:        c.Text,
+		Description: c.Text,
+		Priority:    Priority(c.Int),
+		DueAt:       c.Time,
+	}
+}
+
+// ownedBy() reports whether userID may see the todo. 0 matches any owner,
+// and a todo without an owner only matches 0
+func (todo *Todo) ownedBy(userID int64) bool {
+	return userID == 0 || todo.UserID == userID
+}
+
+// clone() returns a deep copy, so callers never share the stored times
+func (todo *Todo) clone() *Todo {
+	c := *todo
+	if todo.CompletedAt != nil {
+		t := *todo.CompletedAt
+		c.CompletedAt = &t
+	}
+	if todo.DueAt != nil {
+		t := *todo.DueAt
+		c.DueAt = &t
+	}
+	return &c
+}
+
+// matches() is the in-memory version of the WHERE clause in
+// TodoModel.GetAll(). A todo without a due date never matches a due date
+// bound, as comparisons with NULL are never true
+func (s TodoSearch) matches(todo *Todo, now time.Time) bool {
+	switch {
+	case s.ListID != 0 && todo.ListID != s.ListID:
+		return false
+	case !containsWords(todo.Item, s.Item):
+		return false
+	case !containsWords(todo.Description, s.Description):
+		return false
+	case s.Completed != nil && todo.Completed != *s.Completed:
+		return false
+	case s.DueBefore != nil && (todo.DueAt == nil || !todo.DueAt.Before(*s.DueBefore)):
+		return false
+	case s.DueAfter != nil && (todo.DueAt == nil || !todo.DueAt.After(*s.DueAfter)):
+		return false
+	case s.Priority != nil && todo.Priority != *s.Priority:
+		return false
+	}
+	if s.Overdue != nil {
+		overdue := todo.DueAt != nil && todo.DueAt.Before(now) && !todo.Completed
+		if overdue != *s.Overdue {
+			return false
+		}
+	}
+	return true
+}
+
+// containsWords() reports whether every word of term appears in text. An
+// empty term matches everything
+func containsWords(text, term string) bool {
+	wanted := words(term)
+	if len(wanted) == 0 {
+		return true
+	}
+	have := make(map[string]bool)
+	for _, w := range words(text) {
+		have[w] = true
+	}
+	for _, w := range wanted {
+		if !have[w] {
+			return false
+		}
+	}
+	return true
+}
+
+func words(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// compareTodos() orders two todos by a sort column. As in PostgreSQL,
+// missing due dates sort after every date in ascending order and before
+// them in descending order
+func compareTodos(a, b *Todo, column string, desc bool) int {
+	var c int
+	switch column {
+	case "id":
+		c = compareInts(a.ID, b.ID)
+	case "item":
+		c = strings.Compare(a.Item, b.Item)
+	case "description":
+		c = strings.Compare(a.Description, b.Description)
+	case "priority":
+		c = compareInts(int64(a.Priority), int64(b.Priority))
+	case "due_at":
+		switch {
+		case a.DueAt == nil && b.DueAt == nil:
+			c = 0
+		case a.DueAt == nil:
+			c = 1
+		case b.DueAt == nil:
+			c = -1
+		default:
+			c = a.DueAt.Compare(*b.DueAt)
+		}
+	default:
+		panic("unsupported sort column: " + column)
+	}
+	if desc {
+		return -c
+	}
+	return c
+}
+
+func compareInts(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
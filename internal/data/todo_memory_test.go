@@ -0,0 +1,47 @@
+// Filename: internal/data/todo_memory_test.go
+
+package data
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMemoryTodoStore(t *testing.T) {
+	testTodoStore(t, func(t *testing.T) todoStoreFixture {
+		// The memory store does not check lists or users
+		return todoStoreFixture{
+			store: NewMemoryTodoStore(),
+			lists: [2]int64{1, 2},
+			users: [2]int64{1, 2},
+		}
+	})
+}
+
+// The memory store must hand out copies, as a database would, so that
+// callers cannot change stored todos without Update()
+func TestMemoryTodoStoreCopies(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryTodoStore()
+	todo := &Todo{ListID: 1, UserID: 1, Item: "buy milk", Priority: PriorityNormal, DueAt: date("2030-01-01")}
+	err := store.Insert(ctx, todo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	todo.Item = "changed after insert"
+
+	got, err := store.Get(ctx, todo.ID, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got.Item = "changed after get"
+	*got.DueAt = got.DueAt.AddDate(1, 0, 0)
+
+	got, err = store.Get(ctx, todo.ID, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Item != "buy milk" || !got.DueAt.Equal(*date("2030-01-01")) {
+		t.Errorf("stored todo was changed: %+v", got)
+	}
+}
@@ -0,0 +1,259 @@
+// Filename: internal/data/todo_sqlite.go
+
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SQLiteTodoStore is the TodoStore for the SQLite backend. It behaves like
+// TodoModel, with FTS5 in place of PostgreSQL text search. The schema is in
+// migrations/sqlite
+type SQLiteTodoStore struct {
+	DB           *sql.DB
+	QueryTimeout time.Duration
+}
+
+// queryContext() derives the context a single query runs under
+func (m SQLiteTodoStore) queryContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if m.QueryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, m.QueryTimeout)
+}
+
+func (m SQLiteTodoStore) Insert(ctx context.Context, todo *Todo) error {
+	query := `
+		INSERT INTO todolist (list_id, user_id, item, description, completed, completed_at, due_at, priority)
+		VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8)
+		RETURNING id, created_at, version
+	`
+
+	args := []interface{}{
+		todo.ListID,
+		todo.UserID,
+		todo.Item,
+		todo.Description,
+		todo.Completed,
+		sqliteTime(todo.CompletedAt),
+		sqliteTime(todo.DueAt),
+		todo.Priority,
+	}
+
+	ctx, cancel := m.queryContext(ctx)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&todo.ID, &todo.CreatedAt, &todo.Version)
+	return contextError(ctx, err)
+}
+
+// Get() returns a specific Todo belonging to the user. A userID of 0
+// matches any owner
+func (m SQLiteTodoStore) Get(ctx context.Context, id int64, userID int64) (*Todo, error) {
+	// Ensure that there is a valid id
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+	query := `
+		SELECT id, created_at, list_id, COALESCE(user_id, 0), item, description, completed, completed_at, due_at, priority, version
+		FROM todolist
+		WHERE id = ?1 AND (user_id = ?2 OR ?2 = 0)
+	`
+	var todo Todo
+
+	ctx, cancel := m.queryContext(ctx)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id, userID).Scan(
+		&todo.ID,
+		&todo.CreatedAt,
+		&todo.ListID,
+		&todo.UserID,
+		&todo.Item,
+		&todo.Description,
+		&todo.Completed,
+		&todo.CompletedAt,
+		&todo.DueAt,
+		&todo.Priority,
+		&todo.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, contextError(ctx, err)
+		}
+	}
+	return &todo, nil
+}
+
+// Update() edits a Todo, using the version number as an optimistic lock
+func (m SQLiteTodoStore) Update(ctx context.Context, todo *Todo) error {
+	query := `
+		UPDATE todolist
+		SET item = ?1, description = ?2, completed = ?3, completed_at = ?4,
+			due_at = ?5, priority = ?6, version = version + 1
+		WHERE id = ?7 AND (user_id = ?8 OR ?8 = 0) AND version = ?9
+		RETURNING version
+	`
+
+	args := []interface{}{
+		todo.Item,
+		todo.Description,
+		todo.Completed,
+		sqliteTime(todo.CompletedAt),
+		sqliteTime(todo.DueAt),
+		todo.Priority,
+		todo.ID,
+		todo.UserID,
+		todo.Version,
+	}
+
+	ctx, cancel := m.queryContext(ctx)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&todo.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return contextError(ctx, err)
+		}
+	}
+	return nil
+}
+
+// Delete() removes a specific Todo belonging to the user
+func (m SQLiteTodoStore) Delete(ctx context.Context, id int64, userID int64) error {
+	// Ensure that there is a valid id
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+	query := `
+		DELETE FROM todolist
+		WHERE id = ?1 AND (user_id = ?2 OR ?2 = 0)
+	`
+	ctx, cancel := m.queryContext(ctx)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return contextError(ctx, err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// GetAll() returns a filtered, sorted page of the user's todos. An empty
+// search term is tested first because FTS5 rejects an empty MATCH. NULLS
+// LAST and NULLS FIRST keep todos without a due date where PostgreSQL puts
+// them
+func (m SQLiteTodoStore) GetAll(ctx context.Context, userID int64, search TodoSearch, filters Filters) ([]*Todo, Metadata, error) {
+	nulls := "LAST"
+	if filters.sortOrder() == "DESC" {
+		nulls = "FIRST"
+	}
+	keyset, keysetArgs := filters.keyset("?", 13)
+	query := fmt.Sprintf(`
+		SELECT COUNT(*) OVER(), id, created_at, list_id, COALESCE(user_id, 0), item, description, completed, completed_at, due_at, priority, version
+		FROM todolist
+		WHERE (?1 = '' OR id IN (SELECT rowid FROM todolist_fts WHERE todolist_fts MATCH ?1))
+		AND (?2 = '' OR id IN (SELECT rowid FROM todolist_fts WHERE todolist_fts MATCH ?2))
+		AND (completed = ?3 OR ?3 IS NULL)
+		AND (due_at < ?4 OR ?4 IS NULL)
+		AND (due_at > ?5 OR ?5 IS NULL)
+		AND ((COALESCE(due_at < ?12, false) AND NOT completed) = ?6 OR ?6 IS NULL)
+		AND (priority = ?7 OR ?7 IS NULL)
+		AND (list_id = ?8 OR ?8 = 0)
+		AND (user_id = ?9 OR ?9 = 0)
+		AND %s
+		ORDER BY %s %s NULLS %s, id ASC
+		LIMIT ?10 OFFSET ?11`, keyset, filters.sortColumn(), filters.sortOrder(), nulls)
+
+	ctx, cancel := m.queryContext(ctx)
+	defer cancel()
+
+	now := time.Now()
+	args := []interface{}{
+		ftsMatch("item", search.Item),
+		ftsMatch("description", search.Description),
+		search.Completed,
+		sqliteTime(search.DueBefore),
+		sqliteTime(search.DueAfter),
+		search.Overdue,
+		search.Priority,
+		search.ListID,
+		userID,
+		filters.limit(),
+		filters.offset(),
+		sqliteTime(&now),
+	}
+	args = append(args, keysetArgs...)
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, contextError(ctx, err)
+	}
+	defer rows.Close()
+	totalRecords := 0
+	todos := []*Todo{}
+	for rows.Next() {
+		var todo Todo
+		err := rows.Scan(
+			&totalRecords,
+			&todo.ID,
+			&todo.CreatedAt,
+			&todo.ListID,
+			&todo.UserID,
+			&todo.Item,
+			&todo.Description,
+			&todo.Completed,
+			&todo.CompletedAt,
+			&todo.DueAt,
+			&todo.Priority,
+			&todo.Version,
+		)
+		if err != nil {
+			return nil, Metadata{}, contextError(ctx, err)
+		}
+		todos = append(todos, &todo)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, contextError(ctx, err)
+	}
+	metadata := todoMetadata(todos, totalRecords, filters)
+	return todos, metadata, nil
+}
+
+// sqliteTime() converts a time to UTC before it is stored, so that the
+// text SQLite keeps compares in time order. nil stays NULL
+func sqliteTime(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return t.UTC()
+}
+
+// ftsMatch() turns a search term into an FTS5 query which, like
+// plainto_tsquery(), requires every word of the term in column. Each word
+// is quoted, so characters in the term are never read as FTS5 syntax. An
+// empty result means "do not filter"
+func ftsMatch(column, term string) string {
+	terms := words(term)
+	for i, w := range terms {
+		terms[i] = fmt.Sprintf(`%s:"%s"`, column, w)
+	}
+	return strings.Join(terms, " AND ")
+}
@@ -0,0 +1,48 @@
+// Filename: internal/data/todo_sqlite_test.go
+
+//go:build sqlite_fts5
+
+package data
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"Quiz3.zioncastillo.net/internal/migrate"
+	"Quiz3.zioncastillo.net/migrations"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// TestSQLiteTodoStore runs the TodoStore suite against a new database file
+// for every subtest
+func TestSQLiteTodoStore(t *testing.T) {
+	testTodoStore(t, func(t *testing.T) todoStoreFixture {
+		db := newSQLiteTestDB(t)
+		return newDBTodoStoreFixture(t, db, SQLiteTodoStore{DB: db, QueryTimeout: 3 * time.Second}, ListModel{DB: db, sqlite: true})
+	})
+}
+
+// newSQLiteTestDB() returns a migrated database in a file which is removed
+// when the test ends
+func newSQLiteTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "todo.db")
+	db, err := sql.Open("sqlite3", path+"?_foreign_keys=on&_busy_timeout=5000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	migrator, err := migrate.New(db, "sqlite3", migrations.SQLite, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = migrator.Up(context.Background())
+	if err != nil {
+		t.Fatalf("migrating: %v", err)
+	}
+	return db
+}
@@ -0,0 +1,55 @@
+// Filename: internal/data/todo_test.go
+
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"Quiz3.zioncastillo.net/internal/migrate"
+	"Quiz3.zioncastillo.net/migrations"
+	_ "github.com/lib/pq"
+)
+
+// TestTodoModel runs the TodoStore suite against the PostgreSQL database
+// in TODO_TEST_DB_DSN, and is skipped when it is not set. The test deletes
+// every todo, user and list other than the default list, so never point it
+// at a database whose data you want to keep
+func TestTodoModel(t *testing.T) {
+	dsn := os.Getenv("TODO_TEST_DB_DSN")
+	if dsn == "" {
+		t.Skip("TODO_TEST_DB_DSN is not set")
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	migrator, err := migrate.New(db, "postgres", migrations.FS, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = migrator.Up(context.Background())
+	if err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		t.Fatalf("migrating: %v", err)
+	}
+
+	testTodoStore(t, func(t *testing.T) todoStoreFixture {
+		for _, query := range []string{
+			`DELETE FROM todolist`,
+			`DELETE FROM lists WHERE NOT is_default`,
+			`DELETE FROM users`,
+		} {
+			_, err := db.Exec(query)
+			if err != nil {
+				t.Fatalf("emptying the database: %v", err)
+			}
+		}
+		return newDBTodoStoreFixture(t, db, TodoModel{DB: db, QueryTimeout: 3 * time.Second}, ListModel{DB: db})
+	})
+}
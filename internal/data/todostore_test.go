@@ -0,0 +1,415 @@
+// Filename: internal/data/todostore_test.go
+
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+// todoStoreFixture is an empty TodoStore together with the lists and users
+// its todos may refer to. The database backends need real rows for them
+type todoStoreFixture struct {
+	store TodoStore
+	lists [2]int64
+	users [2]int64
+}
+
+// newDBTodoStoreFixture() adds the users and lists that todos in a
+// database-backed store refer to. The database must not hold any users
+func newDBTodoStoreFixture(t *testing.T, db *sql.DB, store TodoStore, lists ListModel) todoStoreFixture {
+	t.Helper()
+	f := todoStoreFixture{store: store}
+	for i, email := range []string{"alice@example.com", "bob@example.com"} {
+		err := db.QueryRow(`
+			INSERT INTO users (name, email, password_hash, activated)
+			VALUES ($1, $2, $3, true)
+			RETURNING id`, "test", email, []byte("not a hash")).Scan(&f.users[i])
+		if err != nil {
+			t.Fatalf("inserting user: %v", err)
+		}
+	}
+	for i := range f.lists {
+		list := &List{UserID: f.users[0], Name: "test"}
+		err := lists.Insert(list)
+		if err != nil {
+			t.Fatalf("inserting list: %v", err)
+		}
+		f.lists[i] = list.ID
+	}
+	return f
+}
+
+// todoSeed describes a todo inserted by seedTodos(). list and user index
+// the fixture's lists and users
+type todoSeed struct {
+	item        string
+	description string
+	priority    Priority
+	dueAt       *time.Time
+	completed   bool
+	list        int
+	user        int
+}
+
+// todoSeeds has duplicate items, descriptions, priorities and due dates so
+// that the id tie break is exercised, and todos without a due date. The
+// expected orders in the tests below refer to these by index
+var todoSeeds = []todoSeed{
+	{"buy milk", "from the shop", PriorityNormal, date("2020-01-01"), false, 0, 0},
+	{"call mom", "sunday", PriorityHigh, nil, false, 0, 0},
+	{"buy bread", "from the bakery", PriorityNormal, date("2030-06-01"), true, 0, 0},
+	{"water plants", "sunday", PriorityLow, date("2030-06-01"), false, 0, 0},
+	{"call mom", "weekly", PriorityUrgent, nil, true, 0, 0},
+	{"pay rent", "monthly", PriorityHigh, date("2020-06-01"), true, 1, 0},
+	{"feed cat", "someone else's", PriorityNormal, date("2020-01-01"), false, 0, 1},
+}
+
+// testTodoStore() checks the behaviour every TodoStore must share.
+// newFixture is called once per subtest and must return an empty store
+func testTodoStore(t *testing.T, newFixture func(t *testing.T) todoStoreFixture) {
+	t.Run("Get", func(t *testing.T) { testTodoStoreGet(t, newFixture(t)) })
+	t.Run("Update", func(t *testing.T) { testTodoStoreUpdate(t, newFixture(t)) })
+	t.Run("Delete", func(t *testing.T) { testTodoStoreDelete(t, newFixture(t)) })
+	t.Run("Filter", func(t *testing.T) { testTodoStoreFilter(t, newFixture(t)) })
+	t.Run("Sort", func(t *testing.T) { testTodoStoreSort(t, newFixture(t)) })
+	t.Run("Page", func(t *testing.T) { testTodoStorePage(t, newFixture(t)) })
+	t.Run("Cursor", func(t *testing.T) { testTodoStoreCursor(t, newFixture(t)) })
+}
+
+func testTodoStoreGet(t *testing.T, f todoStoreFixture) {
+	ctx := context.Background()
+	ids := seedTodos(t, f)
+
+	for _, userID := range []int64{f.users[0], 0} {
+		got, err := f.store.Get(ctx, ids[0], userID)
+		if err != nil {
+			t.Fatalf("Get(%d, user %d): %v", ids[0], userID, err)
+		}
+		want := todoSeeds[0]
+		if got.Item != want.item || got.Description != want.description || got.Priority != want.priority ||
+			got.Completed != want.completed || got.ListID != f.lists[want.list] || got.UserID != f.users[want.user] ||
+			got.DueAt == nil || !got.DueAt.Equal(*want.dueAt) || got.Version != 1 {
+			t.Errorf("Get(%d, user %d) = %+v, want %+v", ids[0], userID, got, want)
+		}
+	}
+
+	for _, test := range []struct {
+		name   string
+		id     int64
+		userID int64
+	}{
+		{"another user's todo", ids[0], f.users[1]},
+		{"missing todo", ids[len(ids)-1] + 100, 0},
+		{"zero id", 0, 0},
+	} {
+		_, err := f.store.Get(ctx, test.id, test.userID)
+		if !errors.Is(err, ErrRecordNotFound) {
+			t.Errorf("%s: got error %v, want %v", test.name, err, ErrRecordNotFound)
+		}
+	}
+}
+
+func testTodoStoreUpdate(t *testing.T, f todoStoreFixture) {
+	ctx := context.Background()
+	ids := seedTodos(t, f)
+
+	todo, err := f.store.Get(ctx, ids[1], f.users[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale := *todo
+	todo.Item = "call dad"
+	todo.Complete()
+	err = f.store.Update(ctx, todo)
+	if err != nil {
+		t.Fatalf("Update(): %v", err)
+	}
+	if todo.Version != 2 {
+		t.Errorf("got version %d after update, want 2", todo.Version)
+	}
+	got, err := f.store.Get(ctx, ids[1], f.users[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Item != "call dad" || !got.Completed || got.CompletedAt == nil || got.Version != 2 {
+		t.Errorf("got %+v after update", got)
+	}
+
+	// An admin, user 0, may update anyone's todo without taking it over
+	admin := *got
+	admin.UserID = 0
+	admin.Priority = PriorityUrgent
+	err = f.store.Update(ctx, &admin)
+	if err != nil {
+		t.Fatalf("Update() as admin: %v", err)
+	}
+	got, err = f.store.Get(ctx, ids[1], f.users[0])
+	if err != nil {
+		t.Fatalf("Get() after admin update: %v", err)
+	}
+	if got.Priority != PriorityUrgent {
+		t.Errorf("got priority %v after admin update, want %v", got.Priority, PriorityUrgent)
+	}
+
+	otherUser := *got
+	otherUser.UserID = f.users[1]
+	missing := *got
+	missing.ID = ids[len(ids)-1] + 100
+	for _, test := range []struct {
+		name string
+		todo Todo
+	}{
+		{"stale version", stale},
+		{"another user's todo", otherUser},
+		{"missing todo", missing},
+	} {
+		err := f.store.Update(ctx, &test.todo)
+		if !errors.Is(err, ErrEditConflict) {
+			t.Errorf("%s: got error %v, want %v", test.name, err, ErrEditConflict)
+		}
+	}
+}
+
+func testTodoStoreDelete(t *testing.T, f todoStoreFixture) {
+	ctx := context.Background()
+	ids := seedTodos(t, f)
+
+	err := f.store.Delete(ctx, ids[0], f.users[1])
+	if !errors.Is(err, ErrRecordNotFound) {
+		t.Errorf("deleting another user's todo: got error %v, want %v", err, ErrRecordNotFound)
+	}
+	err = f.store.Delete(ctx, ids[0], f.users[0])
+	if err != nil {
+		t.Fatalf("Delete(): %v", err)
+	}
+	_, err = f.store.Get(ctx, ids[0], 0)
+	if !errors.Is(err, ErrRecordNotFound) {
+		t.Errorf("Get() after Delete(): got error %v, want %v", err, ErrRecordNotFound)
+	}
+	err = f.store.Delete(ctx, ids[0], f.users[0])
+	if !errors.Is(err, ErrRecordNotFound) {
+		t.Errorf("deleting twice: got error %v, want %v", err, ErrRecordNotFound)
+	}
+	// An admin may delete anyone's todo
+	err = f.store.Delete(ctx, ids[6], 0)
+	if err != nil {
+		t.Errorf("Delete() as admin: %v", err)
+	}
+}
+
+func testTodoStoreFilter(t *testing.T, f todoStoreFixture) {
+	ids := seedTodos(t, f)
+	cutoff := date("2025-01-01")
+
+	tests := []struct {
+		name   string
+		user   int // index into f.users, or -1 for an admin
+		search TodoSearch
+		want   []int
+	}{
+		{"owner", 0, TodoSearch{}, []int{0, 1, 2, 3, 4, 5}},
+		{"other owner", 1, TodoSearch{}, []int{6}},
+		{"admin", -1, TodoSearch{}, []int{0, 1, 2, 3, 4, 5, 6}},
+		{"item", 0, TodoSearch{Item: "buy"}, []int{0, 2}},
+		{"item ignores case", 0, TodoSearch{Item: "MOM"}, []int{1, 4}},
+		{"item needs every word", 0, TodoSearch{Item: "buy bread"}, []int{2}},
+		{"description", 0, TodoSearch{Description: "sunday"}, []int{1, 3}},
+		{"completed", 0, TodoSearch{Completed: ptr(true)}, []int{2, 4, 5}},
+		{"not completed", 0, TodoSearch{Completed: ptr(false)}, []int{0, 1, 3}},
+		{"priority", 0, TodoSearch{Priority: ptr(PriorityNormal)}, []int{0, 2}},
+		{"list", 0, TodoSearch{ListID: f.lists[1]}, []int{5}},
+		{"due before", 0, TodoSearch{DueBefore: cutoff}, []int{0, 5}},
+		{"due after", 0, TodoSearch{DueAfter: cutoff}, []int{2, 3}},
+		{"overdue", 0, TodoSearch{Overdue: ptr(true)}, []int{0}},
+		{"not overdue", 0, TodoSearch{Overdue: ptr(false)}, []int{1, 2, 3, 4, 5}},
+		{"admin overdue", -1, TodoSearch{Overdue: ptr(true)}, []int{0, 6}},
+		{"combined", 0, TodoSearch{Item: "call", Completed: ptr(true)}, []int{4}},
+		{"no match", 0, TodoSearch{Item: "nothing"}, []int{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			userID := int64(0)
+			if test.user >= 0 {
+				userID = f.users[test.user]
+			}
+			got, metadata := getAllTodos(t, f, userID, test.search, Filters{Page: 1, PageSize: 100, Sort: "id"})
+			want := pick(ids, test.want...)
+			if !slices.Equal(todoIDs(got), want) {
+				t.Errorf("got ids %v, want %v", todoIDs(got), want)
+			}
+			if metadata.TotalRecords != len(want) {
+				t.Errorf("got %d total records, want %d", metadata.TotalRecords, len(want))
+			}
+		})
+	}
+}
+
+func testTodoStoreSort(t *testing.T, f todoStoreFixture) {
+	ids := seedTodos(t, f)
+
+	// Ties are broken by id. Todos without a due date come last ascending
+	// and first descending
+	tests := map[string][]int{
+		"id":           {0, 1, 2, 3, 4, 5},
+		"-id":          {5, 4, 3, 2, 1, 0},
+		"item":         {2, 0, 1, 4, 5, 3},
+		"-item":        {3, 5, 1, 4, 0, 2},
+		"description":  {2, 0, 5, 1, 3, 4},
+		"-description": {4, 1, 3, 5, 0, 2},
+		"priority":     {3, 0, 2, 1, 5, 4},
+		"-priority":    {4, 1, 5, 0, 2, 3},
+		"due_at":       {0, 5, 2, 3, 1, 4},
+		"-due_at":      {1, 4, 2, 3, 5, 0},
+	}
+	for _, sort := range todoSortList {
+		t.Run(sort, func(t *testing.T) {
+			got, _ := getAllTodos(t, f, f.users[0], TodoSearch{}, Filters{Page: 1, PageSize: 100, Sort: sort})
+			want := pick(ids, tests[sort]...)
+			if !slices.Equal(todoIDs(got), want) {
+				t.Errorf("got ids %v, want %v", todoIDs(got), want)
+			}
+		})
+	}
+}
+
+func testTodoStorePage(t *testing.T, f todoStoreFixture) {
+	ids := seedTodos(t, f)
+
+	tests := []struct {
+		page     int
+		want     []int
+		metadata Metadata
+	}{
+		{1, []int{0, 1, 2, 3}, Metadata{CurrentPage: 1, PageSize: 4, FirstPage: 1, LastPage: 2, TotalRecords: 6}},
+		{2, []int{4, 5}, Metadata{CurrentPage: 2, PageSize: 4, FirstPage: 1, LastPage: 2, TotalRecords: 6}},
+		// Past the end nothing is counted
+		{3, []int{}, Metadata{}},
+	}
+	for _, test := range tests {
+		got, metadata := getAllTodos(t, f, f.users[0], TodoSearch{}, Filters{Page: test.page, PageSize: 4, Sort: "id"})
+		want := pick(ids, test.want...)
+		if !slices.Equal(todoIDs(got), want) {
+			t.Errorf("page %d: got ids %v, want %v", test.page, todoIDs(got), want)
+		}
+		// Next is only set for cursors, which are tested separately
+		metadata.Next = nil
+		if metadata != test.metadata {
+			t.Errorf("page %d: got metadata %+v, want %+v", test.page, metadata, test.metadata)
+		}
+	}
+}
+
+// testTodoStoreCursor() pages through every sort order with cursors, which
+// must give the same todos as a single offset page. The seeds include
+// duplicate sort keys and todos without a due date, which are the edge
+// cases of Filters.keyset()
+func testTodoStoreCursor(t *testing.T, f todoStoreFixture) {
+	seedTodos(t, f)
+	key := []byte("test key")
+
+	for _, sort := range todoSortList {
+		want, _ := getAllTodos(t, f, f.users[0], TodoSearch{}, Filters{Page: 1, PageSize: 100, Sort: sort})
+		for _, pageSize := range []int{1, 2, 4} {
+			t.Run(fmt.Sprintf("%s/%d", sort, pageSize), func(t *testing.T) {
+				var got []*Todo
+				filters := Filters{Page: 1, PageSize: pageSize, Sort: sort}
+				for pages := 0; ; pages++ {
+					if pages > len(want) {
+						t.Fatalf("still paging after %d pages", pages)
+					}
+					todos, metadata := getAllTodos(t, f, f.users[0], TodoSearch{}, filters)
+					if filters.After != nil && metadata.RemainingRecords != len(want)-len(got) {
+						t.Errorf("got %d remaining records after %d todos, want %d", metadata.RemainingRecords, len(got), len(want)-len(got))
+					}
+					got = append(got, todos...)
+					if metadata.Next == nil {
+						break
+					}
+					// Go through the token, as clients do
+					after, err := DecodeCursor(metadata.Next.Encode(key), key)
+					if err != nil {
+						t.Fatal(err)
+					}
+					filters.After = after
+				}
+				if !slices.Equal(todoIDs(got), todoIDs(want)) {
+					t.Errorf("got ids %v, want %v", todoIDs(got), todoIDs(want))
+				}
+			})
+		}
+	}
+}
+
+// todoSortList is the sort list used by the todo listing handler
+var todoSortList = []string{"id", "item", "description", "due_at", "priority", "-id", "-item", "-description", "-due_at", "-priority"}
+
+// seedTodos() inserts todoSeeds into the fixture and returns their ids in
+// the same order
+func seedTodos(t *testing.T, f todoStoreFixture) []int64 {
+	t.Helper()
+	ids := make([]int64, len(todoSeeds))
+	for i, seed := range todoSeeds {
+		todo := &Todo{
+			ListID:      f.lists[seed.list],
+			UserID:      f.users[seed.user],
+			Item:        seed.item,
+			Description: seed.description,
+			Priority:    seed.priority,
+			DueAt:       seed.dueAt,
+		}
+		if seed.completed {
+			todo.Complete()
+		}
+		err := f.store.Insert(context.Background(), todo)
+		if err != nil {
+			t.Fatalf("inserting %q: %v", seed.item, err)
+		}
+		ids[i] = todo.ID
+	}
+	return ids
+}
+
+func getAllTodos(t *testing.T, f todoStoreFixture, userID int64, search TodoSearch, filters Filters) ([]*Todo, Metadata) {
+	t.Helper()
+	filters.SortList = todoSortList
+	todos, metadata, err := f.store.GetAll(context.Background(), userID, search, filters)
+	if err != nil {
+		t.Fatalf("GetAll(): %v", err)
+	}
+	return todos, metadata
+}
+
+func todoIDs(todos []*Todo) []int64 {
+	ids := make([]int64, len(todos))
+	for i, todo := range todos {
+		ids[i] = todo.ID
+	}
+	return ids
+}
+
+// pick() returns the ids at the given seed indexes
+func pick(ids []int64, indexes ...int) []int64 {
+	picked := make([]int64, len(indexes))
+	for i, index := range indexes {
+		picked[i] = ids[index]
+	}
+	return picked
+}
+
+func date(s string) *time.Time {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return &t
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,72 @@
+// Filename: internal/data/users_sqlite_test.go
+
+//go:build sqlite_fts5
+
+package data
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestUserModelRegister(t *testing.T) {
+	db := newSQLiteTestDB(t)
+	models := NewSQLiteModels(db, 3*time.Second)
+	newUser := func(email string) *User {
+		user := &User{Name: "test", Email: email}
+		err := user.Password.Set("pa55word")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return user
+	}
+
+	alice := newUser("alice@example.com")
+	token, err := models.User.Register(alice, time.Hour, PermissionTodosRead, PermissionTodosWrite)
+	if err != nil {
+		t.Fatalf("registering: %v", err)
+	}
+	user, err := models.User.GetForToken(ScopeActivation, token.Plaintext)
+	if err != nil {
+		t.Fatalf("looking up the activation token: %v", err)
+	}
+	if user.ID != alice.ID {
+		t.Errorf("activation token belongs to user %d, want %d", user.ID, alice.ID)
+	}
+	permissions, err := models.Permission.GetAllForUser(alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(permissions) != 2 || !permissions.Include(PermissionTodosRead) || !permissions.Include(PermissionTodosWrite) {
+		t.Errorf("got permissions %v", permissions)
+	}
+
+	_, err = models.User.Register(newUser("alice@example.com"), time.Hour, PermissionTodosRead)
+	if !errors.Is(err, ErrDuplicateEmail) {
+		t.Errorf("registering a taken email: got error %v, want %v", err, ErrDuplicateEmail)
+	}
+
+	// Without a tokens table the last insert fails, which must take the
+	// user and their permissions with it
+	_, err = db.Exec(`DROP TABLE tokens`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = models.User.Register(newUser("bob@example.com"), time.Hour, PermissionTodosRead)
+	if err == nil {
+		t.Fatal("registering without a tokens table succeeded")
+	}
+	_, err = models.User.GetByEmail("bob@example.com")
+	if !errors.Is(err, ErrRecordNotFound) {
+		t.Errorf("looking up a user whose registration failed: got error %v, want %v", err, ErrRecordNotFound)
+	}
+	var grants int
+	err = db.QueryRow(`SELECT count(*) FROM users_permissions`).Scan(&grants)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if grants != 2 {
+		t.Errorf("got %d permission grants, want alice's 2", grants)
+	}
+}
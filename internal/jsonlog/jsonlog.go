@@ -3,10 +3,13 @@
 package jsonlog
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"io"
+	"log/slog"
 	"os"
-	"runtime/debug"
+	"strings"
 	"sync"
 	"time"
 )
@@ -16,76 +19,176 @@ type Level int8
 
 // Levels start at zero
 const (
-	LevelInfo  Level = iota // value is 0
-	LevelError              // value is 1
-	LevelFatal              // value is 2
-	LevelOff                // value is 3
+	LevelDebug Level = iota // value is 0
+	LevelInfo               // value is 1
+	LevelWarn               // value is 2
+	LevelError              // value is 3
+	LevelFatal              // value is 4
+	LevelOff                // value is 5
 )
 
 // The severity levels as a human-readeable friendly format
 func (l Level) String() string {
 	switch l {
+	case LevelDebug:
+		return "DEBUG"
 	case LevelInfo:
 		return "INFO"
+	case LevelWarn:
+		return "WARN"
 	case LevelError:
 		return "ERROR"
 	case LevelFatal:
 		return "FATAL"
+	case LevelOff:
+		return "OFF"
 	default:
 		return ""
 	}
 }
 
+// ParseLevel() converts a level name such as "warn" into a Level
+func ParseLevel(s string) (Level, error) {
+	for l := LevelDebug; l <= LevelOff; l++ {
+		if strings.EqualFold(s, l.String()) {
+			return l, nil
+		}
+	}
+	return LevelInfo, fmt.Errorf("unknown log level %q", s)
+}
+
+// A Sink is a destination for log entries. Each sink only receives entries
+// at or above its own minimum level
+type Sink struct {
+	Out      io.Writer
+	MinLevel Level
+}
+
 // Define a custom logger
 type Logger struct {
-	out      io.Writer
+	sinks []Sink
+	// minLevel is the lowest level any sink accepts
 	minLevel Level
-	mu       sync.Mutex
+	fields   map[string]any
+	// When handler is set entries are passed to it instead of written to out
+	handler slog.Handler
+	// Trace and redaction settings are fixed by New() and shared with children
+	opts *options
+	// The mutex is shared with child loggers since they write to the same sinks
+	mu *sync.Mutex
 }
 
-// The New() function creates a new instance of Logger
-func New(out io.Writer, minLevel Level) *Logger {
-	return &Logger{
-		out:      out,
+// The New() function creates a new instance of Logger. By default Error and
+// Fatal entries carry a full stack trace and DefaultRedactKeys are masked.
+// More destinations can be added with WithSink()
+func New(out io.Writer, minLevel Level, opts ...Option) *Logger {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(o)
+	}
+	l := &Logger{
 		minLevel: minLevel,
+		opts:     o,
+		mu:       &sync.Mutex{},
+	}
+	if out != nil {
+		l.sinks = append(l.sinks, Sink{Out: out, MinLevel: minLevel})
+	}
+	for _, sink := range o.sinks {
+		l.sinks = append(l.sinks, sink)
+		if sink.MinLevel < l.minLevel {
+			l.minLevel = sink.MinLevel
+		}
+	}
+	return l
+}
+
+// With() returns a child logger which adds fields to the properties of
+// every entry it writes. Properties passed to a call win over fields
+func (l *Logger) With(fields map[string]any) *Logger {
+	merged := make(map[string]any, len(l.fields)+len(fields))
+	for k, v := range l.fields {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	return &Logger{
+		sinks:    l.sinks,
+		minLevel: l.minLevel,
+		fields:   merged,
+		handler:  l.handler,
+		opts:     l.opts,
+		mu:       l.mu,
 	}
 }
 
 // Helper methods
-func (l *Logger) PrintInfo(message string, properties map[string]string) {
+func (l *Logger) PrintDebug(message string, properties map[string]any) {
+	l.print(LevelDebug, message, properties)
+}
+
+func (l *Logger) PrintInfo(message string, properties map[string]any) {
 	l.print(LevelInfo, message, properties)
 }
 
-func (l *Logger) PrintError(err error, properties map[string]string) {
+func (l *Logger) PrintWarn(message string, properties map[string]any) {
+	l.print(LevelWarn, message, properties)
+}
+
+func (l *Logger) PrintError(err error, properties map[string]any) {
 	l.print(LevelError, err.Error(), properties)
 }
 
-func (l *Logger) PrintFatal(err error, properties map[string]string) {
+func (l *Logger) PrintFatal(err error, properties map[string]any) {
 	l.print(LevelFatal, err.Error(), properties)
 	os.Exit(1)
 }
 
-func (l *Logger) print(level Level, message string, properties map[string]string) (int, error) {
+func (l *Logger) print(level Level, message string, properties map[string]any) (int, error) {
+	return l.output(level, time.Now(), message, properties)
+}
+
+// output() writes a single entry. It is shared by the Print methods and the
+// slog Handler, which supplies the time recorded by slog
+func (l *Logger) output(level Level, t time.Time, message string, properties map[string]any) (int, error) {
 	// Ensure severity level is at least the minimum
 	if level < l.minLevel {
 		return 0, nil
 	}
+	// Merge the child logger fields underneath the call properties
+	if len(l.fields) > 0 {
+		merged := make(map[string]any, len(l.fields)+len(properties))
+		for k, v := range l.fields {
+			merged[k] = v
+		}
+		for k, v := range properties {
+			merged[k] = v
+		}
+		properties = merged
+	}
+	// Mask sensitive values before they can reach the output
+	properties = l.opts.redact(properties)
 	// Create a struct for holding the log entry data
 	data := struct {
-		Level      string            `json:"level"`
-		Time       string            `json:"time"`
-		Message    string            `json:"message"`
-		Properties map[string]string `json:"properties,omitempty"`
-		Trace      string            `json:"trace,omitempty"`
+		Level      string         `json:"level"`
+		Time       string         `json:"time"`
+		Message    string         `json:"message"`
+		Properties map[string]any `json:"properties,omitempty"`
+		Trace      string         `json:"trace,omitempty"`
 	}{
 		Level:      level.String(),
-		Time:       time.Now().UTC().Format(time.RFC3339),
+		Time:       t.UTC().Format(time.RFC3339),
 		Message:    message,
 		Properties: properties,
 	}
 	// Should we include the stack trace?
-	if level >= LevelError {
-		data.Trace = string(debug.Stack())
+	if l.opts.traceLevels[level] {
+		data.Trace = l.opts.trace()
+	}
+	// Hand the entry over to slog if this logger was built with NewFromSlog()
+	if l.handler != nil {
+		return 0, l.handler.Handle(context.Background(), toRecord(level, t, data.Message, data.Properties, data.Trace))
 	}
 	// Encode the log entry to JSON
 	var entry []byte
@@ -93,10 +196,28 @@ func (l *Logger) print(level Level, message string, properties map[string]string
 	if err != nil {
 		entry = []byte(LevelError.String() + ": unable to marshal log message: " + err.Error())
 	}
-	// Prepare to write the log entry
+	entry = append(entry, '\n')
+	// Prepare to write the log entry to every sink that wants it. The first
+	// error is reported but does not stop the other sinks
 	l.mu.Lock()
 	defer l.mu.Unlock()
-	return l.out.Write(append(entry, '\n'))
+	var (
+		written  int
+		firstErr error
+	)
+	for _, sink := range l.sinks {
+		if level < sink.MinLevel {
+			continue
+		}
+		n, err := sink.Out.Write(entry)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		if n > written {
+			written = n
+		}
+	}
+	return written, firstErr
 }
 
 // Implement the io.Writer interface
@@ -0,0 +1,156 @@
+// Filename: internal/jsonlog/options.go
+
+package jsonlog
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"runtime"
+	"strings"
+)
+
+// RedactedValue replaces the value of any property whose key is redacted
+const RedactedValue = "[REDACTED]"
+
+// DefaultRedactKeys are masked unless WithRedactKeys() says otherwise
+var DefaultRedactKeys = []string{"password", "token", "dsn", "authorization", "secret"}
+
+// An Option configures a Logger created by New()
+type Option func(*options)
+
+type options struct {
+	// traceLevels[l] reports whether entries at level l carry a stack trace
+	traceLevels [LevelOff + 1]bool
+	// traceDepth limits the number of frames in a trace. Zero means no limit
+	traceDepth int
+	// redactKeys holds lower-cased property keys whose values are masked
+	redactKeys map[string]bool
+	// sinks are written to in addition to the out passed to New()
+	sinks []Sink
+}
+
+func defaultOptions() *options {
+	o := &options{}
+	o.traceLevels[LevelError] = true
+	o.traceLevels[LevelFatal] = true
+	o.redactKeys = redactSet(DefaultRedactKeys)
+	return o
+}
+
+// WithTraceLevels() captures a stack trace only for entries at the given
+// levels. Calling it with no levels turns traces off
+func WithTraceLevels(levels ...Level) Option {
+	return func(o *options) {
+		o.traceLevels = [LevelOff + 1]bool{}
+		for _, l := range levels {
+			if l >= LevelDebug && l <= LevelOff {
+				o.traceLevels[l] = true
+			}
+		}
+	}
+}
+
+// WithTraceDepth() trims stack traces to at most depth frames
+func WithTraceDepth(depth int) Option {
+	return func(o *options) {
+		o.traceDepth = depth
+	}
+}
+
+// WithSink() adds another destination which receives entries at or above
+// minLevel, independently of the level passed to New()
+func WithSink(out io.Writer, minLevel Level) Option {
+	return func(o *options) {
+		if out != nil {
+			o.sinks = append(o.sinks, Sink{Out: out, MinLevel: minLevel})
+		}
+	}
+}
+
+// WithRedactKeys() replaces the list of property keys whose values are
+// masked. Keys match case-insensitively, including inside nested objects
+func WithRedactKeys(keys ...string) Option {
+	return func(o *options) {
+		o.redactKeys = redactSet(keys)
+	}
+}
+
+// redactSet() ignores surrounding spaces, so "token, password" works, and
+// skips empty keys
+func redactSet(keys []string) map[string]bool {
+	set := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		k = strings.TrimSpace(k)
+		if k == "" {
+			continue
+		}
+		set[strings.ToLower(k)] = true
+	}
+	return set
+}
+
+// redact() returns a copy of properties with sensitive values masked. The
+// caller's map is never modified
+func (o *options) redact(properties map[string]any) map[string]any {
+	if len(o.redactKeys) == 0 || len(properties) == 0 {
+		return properties
+	}
+	clean := make(map[string]any, len(properties))
+	for k, v := range properties {
+		switch {
+		case o.redactKeys[strings.ToLower(k)]:
+			clean[k] = RedactedValue
+		case isMap(v):
+			clean[k] = o.redact(v.(map[string]any))
+		default:
+			clean[k] = v
+		}
+	}
+	return clean
+}
+
+func isMap(v any) bool {
+	_, ok := v.(map[string]any)
+	return ok
+}
+
+// The import path of this package, used to drop our own frames from traces
+var packagePath = reflect.TypeOf(Logger{}).PkgPath()
+
+// trace() formats the calling goroutine's stack in the same layout as
+// debug.Stack(), skipping frames inside jsonlog and log/slog
+func (o *options) trace() string {
+	// A full buffer may have cut the stack short, so grow it until the whole
+	// stack fits
+	pcs := make([]uintptr, 64)
+	n := runtime.Callers(2, pcs)
+	for n == len(pcs) {
+		pcs = make([]uintptr, 2*len(pcs))
+		n = runtime.Callers(2, pcs)
+	}
+	frames := runtime.CallersFrames(pcs[:n])
+
+	var b strings.Builder
+	written := 0
+	skipping := true
+	for {
+		frame, more := frames.Next()
+		if skipping && (strings.HasPrefix(frame.Function, packagePath+".") || strings.HasPrefix(frame.Function, "log/slog.")) {
+			if !more {
+				break
+			}
+			continue
+		}
+		skipping = false
+		if o.traceDepth > 0 && written >= o.traceDepth {
+			break
+		}
+		fmt.Fprintf(&b, "%s\n\t%s:%d\n", frame.Function, frame.File, frame.Line)
+		written++
+		if !more {
+			break
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,53 @@
+// Filename: internal/jsonlog/options_test.go
+
+package jsonlog_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"Quiz3.zioncastillo.net/internal/jsonlog"
+)
+
+// logFromDepth() calls itself until depth frames are on the stack and then
+// logs a warning. It lives outside package jsonlog, whose frames are left
+// out of traces
+func logFromDepth(l *jsonlog.Logger, depth int) {
+	if depth > 0 {
+		logFromDepth(l, depth-1)
+		return
+	}
+	l.PrintWarn("deep", nil)
+}
+
+func TestTraceDepth(t *testing.T) {
+	tests := []struct {
+		name  string
+		depth int
+		min   int
+		max   int
+	}{
+		// Deeper than the initial buffer of 64 frames
+		{"unlimited", 0, 201, 1 << 30},
+		{"limited", 10, 10, 10},
+	}
+	for _, test := range tests {
+		var buf bytes.Buffer
+		l := jsonlog.New(&buf, jsonlog.LevelWarn, jsonlog.WithTraceLevels(jsonlog.LevelWarn), jsonlog.WithTraceDepth(test.depth))
+		logFromDepth(l, 200)
+
+		var entry struct {
+			Trace string `json:"trace"`
+		}
+		err := json.Unmarshal(buf.Bytes(), &entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		frames := strings.Count(entry.Trace, "\n\t")
+		if frames < test.min || frames > test.max {
+			t.Errorf("%s: got %d frames, want between %d and %d", test.name, frames, test.min, test.max)
+		}
+	}
+}
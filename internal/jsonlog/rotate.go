@@ -0,0 +1,253 @@
+// Filename: internal/jsonlog/rotate.go
+
+package jsonlog
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The timestamp added to the name of a rotated file. It sorts chronologically
+const rotateTimeFormat = "20060102T150405.000000000"
+
+// RotateConfig controls when a RotatingFile starts a new file and how many
+// old files it keeps. Zero values disable the corresponding rule
+type RotateConfig struct {
+	MaxSize    int64         // rotate once the file would grow past this many bytes
+	Interval   time.Duration // rotate once the file has been open this long
+	MaxBackups int           // keep at most this many rotated files
+	MaxAge     time.Duration // delete rotated files older than this
+	Compress   bool          // gzip rotated files
+}
+
+// RotatingFile is an io.Writer which appends to a file and moves it aside
+// as "<name>-<time><ext>" when it gets too big or too old
+type RotatingFile struct {
+	path     string
+	cfg      RotateConfig
+	mu       sync.Mutex
+	file     *os.File
+	size     int64
+	openedAt time.Time
+	// wg tracks background compression so Close() can wait for it, and
+	// bgMu runs one compression and clean up at a time
+	wg   sync.WaitGroup
+	bgMu sync.Mutex
+}
+
+// The NewRotatingFile() function opens path for appending, creating the
+// directory if needed
+func NewRotatingFile(path string, cfg RotateConfig) (*RotatingFile, error) {
+	rf := &RotatingFile{
+		path: path,
+		cfg:  cfg,
+	}
+	err := os.MkdirAll(filepath.Dir(path), 0o755)
+	if err != nil {
+		return nil, err
+	}
+	err = rf.open()
+	if err != nil {
+		return nil, err
+	}
+	return rf, nil
+}
+
+// Write() appends p to the current file, rotating first if required. A
+// single entry is never split across two files
+func (rf *RotatingFile) Write(p []byte) (int, error) {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+
+	if rf.file == nil {
+		return 0, os.ErrClosed
+	}
+	if rf.shouldRotate(int64(len(p))) {
+		err := rf.rotate()
+		if err != nil {
+			if rf.file == nil {
+				return 0, err
+			}
+			// rotate() reopened the old file, so the entry still gets written
+			fmt.Fprintf(os.Stderr, "jsonlog: unable to rotate %s: %v\n", rf.path, err)
+		}
+	}
+	n, err := rf.file.Write(p)
+	rf.size += int64(n)
+	return n, err
+}
+
+// Rotate() forces a rotation, for example from a SIGHUP handler
+func (rf *RotatingFile) Rotate() error {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	if rf.file == nil {
+		return os.ErrClosed
+	}
+	return rf.rotate()
+}
+
+// Close() closes the current file and waits for rotated files to finish
+// compressing
+func (rf *RotatingFile) Close() error {
+	rf.mu.Lock()
+	var err error
+	if rf.file != nil {
+		err = rf.file.Close()
+		rf.file = nil
+	}
+	rf.mu.Unlock()
+	rf.wg.Wait()
+	return err
+}
+
+func (rf *RotatingFile) open() error {
+	file, err := os.OpenFile(rf.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	rf.file = file
+	rf.size = info.Size()
+	rf.openedAt = time.Now()
+	return nil
+}
+
+func (rf *RotatingFile) shouldRotate(incoming int64) bool {
+	// An empty file is never rotated, even for an oversized entry
+	if rf.size == 0 {
+		return false
+	}
+	if rf.cfg.MaxSize > 0 && rf.size+incoming > rf.cfg.MaxSize {
+		return true
+	}
+	if rf.cfg.Interval > 0 && time.Since(rf.openedAt) >= rf.cfg.Interval {
+		return true
+	}
+	return false
+}
+
+// rotate() must be called with rf.mu held. If the file cannot be moved
+// aside, rf.path is opened again so that logging carries on
+func (rf *RotatingFile) rotate() error {
+	err := rf.file.Close()
+	rf.file = nil
+	if err != nil {
+		return rf.reopen(err)
+	}
+
+	ext := filepath.Ext(rf.path)
+	base := strings.TrimSuffix(rf.path, ext)
+	rotated := fmt.Sprintf("%s-%s%s", base, time.Now().UTC().Format(rotateTimeFormat), ext)
+	err = os.Rename(rf.path, rotated)
+	if err != nil {
+		return rf.reopen(err)
+	}
+	err = rf.open()
+	if err != nil {
+		return err
+	}
+
+	// Compression and clean up happen off the write path
+	rf.wg.Add(1)
+	go func() {
+		defer rf.wg.Done()
+		rf.bgMu.Lock()
+		defer rf.bgMu.Unlock()
+		if rf.cfg.Compress {
+			// On failure the uncompressed file is kept, which is still usable.
+			// A missing file was already removed by an earlier clean up
+			if err := compressFile(rotated); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				fmt.Fprintf(os.Stderr, "jsonlog: unable to compress %s: %v\n", rotated, err)
+			}
+		}
+		rf.removeOld()
+	}()
+	return nil
+}
+
+// reopen() opens rf.path again after a failed rotation and returns the
+// rotation error, together with any error from opening the file
+func (rf *RotatingFile) reopen(err error) error {
+	if openErr := rf.open(); openErr != nil {
+		return errors.Join(err, openErr)
+	}
+	return err
+}
+
+// removeOld() deletes rotated files beyond MaxBackups or older than MaxAge
+func (rf *RotatingFile) removeOld() {
+	if rf.cfg.MaxBackups <= 0 && rf.cfg.MaxAge <= 0 {
+		return
+	}
+	ext := filepath.Ext(rf.path)
+	base := strings.TrimSuffix(rf.path, ext)
+	matches, err := filepath.Glob(base + "-*" + ext + "*")
+	if err != nil {
+		return
+	}
+	type backup struct {
+		path string
+		at   time.Time
+	}
+	var backups []backup
+	for _, m := range matches {
+		stamp := strings.TrimPrefix(m, base+"-")
+		stamp = strings.TrimSuffix(strings.TrimSuffix(stamp, ".gz"), ext)
+		at, err := time.Parse(rotateTimeFormat, stamp)
+		if err != nil {
+			// Not one of ours
+			continue
+		}
+		backups = append(backups, backup{path: m, at: at})
+	}
+	// Newest first
+	sort.Slice(backups, func(i, j int) bool { return backups[i].at.After(backups[j].at) })
+	for i, b := range backups {
+		tooMany := rf.cfg.MaxBackups > 0 && i >= rf.cfg.MaxBackups
+		tooOld := rf.cfg.MaxAge > 0 && time.Since(b.at) > rf.cfg.MaxAge
+		if tooMany || tooOld {
+			os.Remove(b.path)
+		}
+	}
+}
+
+// compressFile() replaces path with path.gz
+func compressFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(dst)
+	_, err = io.Copy(zw, src)
+	if err == nil {
+		err = zw.Close()
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path + ".gz")
+		return err
+	}
+	return os.Remove(path)
+}
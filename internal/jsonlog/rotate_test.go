@@ -0,0 +1,205 @@
+// Filename: internal/jsonlog/rotate_test.go
+
+package jsonlog
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestRotatingFile() opens app.log in a fresh directory and closes it,
+// waiting for compression, when the test ends
+func newTestRotatingFile(t *testing.T, cfg RotateConfig) (*RotatingFile, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "app.log")
+	rf, err := NewRotatingFile(path, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { rf.Close() })
+	return rf, path
+}
+
+func write(t *testing.T, rf *RotatingFile, entries ...string) {
+	t.Helper()
+	for _, entry := range entries {
+		_, err := rf.Write([]byte(entry))
+		if err != nil {
+			t.Fatalf("writing %q: %v", entry, err)
+		}
+	}
+}
+
+// backups() returns the rotated files next to path, oldest first
+func backups(t *testing.T, path string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), "app-") {
+			names = append(names, filepath.Join(filepath.Dir(path), entry.Name()))
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// contents() reads a log file, uncompressing it if needed
+func contents(t *testing.T, path string) string {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		r = zr
+	}
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("%s: %v", path, err)
+	}
+	return string(b)
+}
+
+// rotatedBackup() returns the name a rotation at the given time would use
+func rotatedBackup(path string, at time.Time) string {
+	return strings.TrimSuffix(path, ".log") + "-" + at.UTC().Format(rotateTimeFormat) + ".log"
+}
+
+func TestRotatingFileSize(t *testing.T) {
+	rf, path := newTestRotatingFile(t, RotateConfig{MaxSize: 10})
+	// The first entry is bigger than MaxSize but goes in whole, as the file
+	// is empty. Every later entry would take the file past 10 bytes
+	write(t, rf, "an oversized entry\n", "first\n", "second\n", "third\n")
+
+	got := backups(t, path)
+	want := []string{"an oversized entry\n", "first\n", "second\n"}
+	if len(got) != len(want) {
+		t.Fatalf("got backups %v, want %d", got, len(want))
+	}
+	for i := range want {
+		if c := contents(t, got[i]); c != want[i] {
+			t.Errorf("%s: got %q, want %q", got[i], c, want[i])
+		}
+	}
+	if c := contents(t, path); c != "third\n" {
+		t.Errorf("current file: got %q, want %q", c, "third\n")
+	}
+}
+
+func TestRotatingFileInterval(t *testing.T) {
+	rf, path := newTestRotatingFile(t, RotateConfig{Interval: 50 * time.Millisecond})
+	write(t, rf, "first\n", "second\n")
+	if got := backups(t, path); len(got) != 0 {
+		t.Fatalf("rotated before the interval: %v", got)
+	}
+	time.Sleep(60 * time.Millisecond)
+	write(t, rf, "third\n")
+
+	got := backups(t, path)
+	if len(got) != 1 {
+		t.Fatalf("got backups %v, want 1", got)
+	}
+	if c := contents(t, got[0]); c != "first\nsecond\n" {
+		t.Errorf("backup: got %q", c)
+	}
+	if c := contents(t, path); c != "third\n" {
+		t.Errorf("current file: got %q", c)
+	}
+}
+
+func TestRotatingFileMaxBackups(t *testing.T) {
+	rf, path := newTestRotatingFile(t, RotateConfig{MaxSize: 1, MaxBackups: 2})
+	write(t, rf, "1\n", "2\n", "3\n", "4\n", "5\n")
+	rf.Close()
+
+	got := backups(t, path)
+	want := []string{"3\n", "4\n"}
+	if len(got) != len(want) {
+		t.Fatalf("got backups %v, want %d", got, len(want))
+	}
+	for i := range want {
+		if c := contents(t, got[i]); c != want[i] {
+			t.Errorf("%s: got %q, want %q", got[i], c, want[i])
+		}
+	}
+}
+
+func TestRotatingFileMaxAge(t *testing.T) {
+	rf, path := newTestRotatingFile(t, RotateConfig{MaxAge: 24 * time.Hour})
+	old := rotatedBackup(path, time.Now().Add(-48*time.Hour))
+	recent := rotatedBackup(path, time.Now().Add(-time.Hour))
+	// Files which do not carry a rotation timestamp are left alone
+	unrelated := strings.TrimSuffix(path, ".log") + "-notes.log"
+	for _, name := range []string{old, recent, unrelated} {
+		err := os.WriteFile(name, []byte("x\n"), 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(t, rf, "first\n")
+	err := rf.Rotate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rf.Close()
+
+	got := backups(t, path)
+	if len(got) != 3 {
+		t.Fatalf("got backups %v, want 3", got)
+	}
+	for _, name := range got {
+		if name == old {
+			t.Errorf("%s is older than MaxAge but was kept", name)
+		}
+	}
+	for _, name := range []string{recent, unrelated} {
+		if _, err := os.Stat(name); err != nil {
+			t.Errorf("%s should have been kept: %v", name, err)
+		}
+	}
+}
+
+func TestRotatingFileCompress(t *testing.T) {
+	rf, path := newTestRotatingFile(t, RotateConfig{Compress: true, MaxBackups: 1})
+	write(t, rf, "first\n")
+	if err := rf.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	write(t, rf, "second\n")
+	if err := rf.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	write(t, rf, "third\n")
+	// Close() waits for compression and clean up to finish
+	rf.Close()
+
+	got := backups(t, path)
+	if len(got) != 1 || !strings.HasSuffix(got[0], ".log.gz") {
+		t.Fatalf("got backups %v, want one .log.gz", got)
+	}
+	if c := contents(t, got[0]); c != "second\n" {
+		t.Errorf("backup: got %q, want %q", c, "second\n")
+	}
+	if c := contents(t, path); c != "third\n" {
+		t.Errorf("current file: got %q", c)
+	}
+	if _, err := rf.Write([]byte("late\n")); err != os.ErrClosed {
+		t.Errorf("writing after Close: got error %v, want %v", err, os.ErrClosed)
+	}
+}
@@ -0,0 +1,176 @@
+// Filename: internal/jsonlog/slog.go
+
+package jsonlog
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"time"
+)
+
+// Handler is a slog.Handler which writes through a Logger, so that anything
+// logged with log/slog ends up in the same JSON stream. Attributes become
+// properties and groups become nested objects
+type Handler struct {
+	logger *Logger
+	fields map[string]any
+	groups []string
+}
+
+// NewHandler() returns a slog.Handler backed by l
+func NewHandler(l *Logger) *Handler {
+	return &Handler{logger: l}
+}
+
+// Enabled() reports whether the Logger would write an entry at level
+func (h *Handler) Enabled(_ context.Context, level slog.Level) bool {
+	return fromSlogLevel(level) >= h.logger.minLevel
+}
+
+// Handle() writes the record as a single entry
+func (h *Handler) Handle(_ context.Context, r slog.Record) error {
+	properties := cloneFields(h.fields)
+	r.Attrs(func(a slog.Attr) bool {
+		properties = addAttr(properties, h.groups, a)
+		return true
+	})
+	t := r.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	_, err := h.logger.output(fromSlogLevel(r.Level), t, r.Message, properties)
+	return err
+}
+
+// WithAttrs() returns a Handler which adds attrs to every entry
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	fields := cloneFields(h.fields)
+	for _, a := range attrs {
+		fields = addAttr(fields, h.groups, a)
+	}
+	return &Handler{logger: h.logger, fields: fields, groups: h.groups}
+}
+
+// WithGroup() returns a Handler which nests later attributes under name
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	groups := make([]string, len(h.groups), len(h.groups)+1)
+	copy(groups, h.groups)
+	return &Handler{logger: h.logger, fields: h.fields, groups: append(groups, name)}
+}
+
+// NewFromSlog() returns a Logger whose entries are passed to h instead of
+// being written as JSON. It lets code written against jsonlog run in a
+// service which has standardised on log/slog
+func NewFromSlog(h slog.Handler, minLevel Level, opts ...Option) *Logger {
+	l := New(nil, minLevel, opts...)
+	l.handler = h
+	return l
+}
+
+// fromSlogLevel() maps slog's open-ended levels onto ours
+func fromSlogLevel(level slog.Level) Level {
+	switch {
+	case level < slog.LevelInfo:
+		return LevelDebug
+	case level < slog.LevelWarn:
+		return LevelInfo
+	case level < slog.LevelError:
+		return LevelWarn
+	default:
+		return LevelError
+	}
+}
+
+// toSlogLevel() maps our levels onto slog's. Fatal has no slog equivalent so
+// it is logged above Error
+func toSlogLevel(level Level) slog.Level {
+	switch level {
+	case LevelDebug:
+		return slog.LevelDebug
+	case LevelInfo:
+		return slog.LevelInfo
+	case LevelWarn:
+		return slog.LevelWarn
+	case LevelError:
+		return slog.LevelError
+	default:
+		return slog.LevelError + 4
+	}
+}
+
+// toRecord() converts an entry into a slog.Record. Properties are added in
+// key order so the output is stable
+func toRecord(level Level, t time.Time, message string, properties map[string]any, trace string) slog.Record {
+	r := slog.NewRecord(t, toSlogLevel(level), message, 0)
+	keys := make([]string, 0, len(properties))
+	for k := range properties {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		r.AddAttrs(slog.Any(k, properties[k]))
+	}
+	if trace != "" {
+		r.AddAttrs(slog.String("trace", trace))
+	}
+	return r
+}
+
+// addAttr() stores a resolved attribute in fields under the open groups,
+// creating nested maps as needed. Empty attributes are dropped as slog asks
+func addAttr(fields map[string]any, groups []string, a slog.Attr) map[string]any {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return fields
+	}
+	if fields == nil {
+		fields = map[string]any{}
+	}
+	// Walk down to the map for the innermost open group
+	target := fields
+	for _, g := range groups {
+		child, ok := target[g].(map[string]any)
+		if !ok {
+			child = map[string]any{}
+			target[g] = child
+		}
+		target = child
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		// An inline group with no key merges its attributes into the parent
+		subGroups := groups
+		if a.Key != "" {
+			subGroups = append(append([]string{}, groups...), a.Key)
+		}
+		for _, ga := range a.Value.Group() {
+			fields = addAttr(fields, subGroups, ga)
+		}
+		return fields
+	}
+	// Errors have no exported fields, so log their message instead
+	if err, ok := a.Value.Any().(error); ok {
+		target[a.Key] = err.Error()
+		return fields
+	}
+	target[a.Key] = a.Value.Any()
+	return fields
+}
+
+// cloneFields() deep copies the nested maps so handlers can share a parent
+func cloneFields(fields map[string]any) map[string]any {
+	if fields == nil {
+		return nil
+	}
+	clone := make(map[string]any, len(fields))
+	for k, v := range fields {
+		if m, ok := v.(map[string]any); ok {
+			v = cloneFields(m)
+		}
+		clone[k] = v
+	}
+	return clone
+}
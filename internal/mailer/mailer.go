@@ -0,0 +1,89 @@
+// Filename: internal/mailer/mailer.go
+
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	htmltemplate "html/template"
+	"text/template"
+	"time"
+
+	"github.com/go-mail/mail/v2"
+)
+
+// The email templates are compiled into the binary
+//
+//go:embed "templates"
+var templateFS embed.FS
+
+// A Sender delivers a templated email. The application only depends on this
+// interface so another transport can be swapped in
+type Sender interface {
+	Send(recipient, templateFile string, data interface{}) error
+}
+
+// Mailer sends email through an SMTP server
+type Mailer struct {
+	dialer *mail.Dialer
+	sender string
+}
+
+// The New() function creates a new instance of Mailer. Point it at a local
+// catcher such as MailHog (localhost:1025, no credentials) while developing
+func New(host string, port int, username, password, sender string) Mailer {
+	dialer := mail.NewDialer(host, port, username, password)
+	dialer.Timeout = 5 * time.Second
+
+	return Mailer{
+		dialer: dialer,
+		sender: sender,
+	}
+}
+
+// Send() renders the "subject", "plainBody" and "htmlBody" templates in
+// templateFile and emails the result to the recipient
+func (m Mailer) Send(recipient, templateFile string, data interface{}) error {
+	// The subject and plain text body are not HTML so we use text/template
+	tmpl, err := template.New("email").ParseFS(templateFS, "templates/"+templateFile)
+	if err != nil {
+		return err
+	}
+	subject := new(bytes.Buffer)
+	err = tmpl.ExecuteTemplate(subject, "subject", data)
+	if err != nil {
+		return err
+	}
+	plainBody := new(bytes.Buffer)
+	err = tmpl.ExecuteTemplate(plainBody, "plainBody", data)
+	if err != nil {
+		return err
+	}
+	// html/template escapes anything we interpolate into the HTML body
+	htmlTmpl, err := htmltemplate.New("email").ParseFS(templateFS, "templates/"+templateFile)
+	if err != nil {
+		return err
+	}
+	htmlBody := new(bytes.Buffer)
+	err = htmlTmpl.ExecuteTemplate(htmlBody, "htmlBody", data)
+	if err != nil {
+		return err
+	}
+
+	msg := mail.NewMessage()
+	msg.SetHeader("To", recipient)
+	msg.SetHeader("From", m.sender)
+	msg.SetHeader("Subject", subject.String())
+	msg.SetBody("text/plain", plainBody.String())
+	msg.AddAlternative("text/html", htmlBody.String())
+
+	// Retry a few times in case the SMTP server is briefly unavailable
+	for i := 1; i <= 3; i++ {
+		err = m.dialer.DialAndSend(msg)
+		if err == nil {
+			return nil
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+	return err
+}
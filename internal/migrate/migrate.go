@@ -0,0 +1,377 @@
+// Filename: internal/migrate/migrate.go
+
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"Quiz3.zioncastillo.net/internal/jsonlog"
+)
+
+// advisoryLockID is an arbitrary key for pg_advisory_lock(). Only one
+// process holding it may change the schema at a time
+const advisoryLockID = 7264817303
+
+// dialect holds the statements which differ between database drivers
+type dialect struct {
+	lock        string
+	unlock      string
+	createTable string
+}
+
+var dialects = map[string]dialect{
+	"postgres": {
+		lock:   `SELECT pg_advisory_lock($1)`,
+		unlock: `SELECT pg_advisory_unlock($1)`,
+		createTable: `
+			CREATE TABLE IF NOT EXISTS schema_migrations (
+				version bigint PRIMARY KEY,
+				name text NOT NULL,
+				checksum text NOT NULL,
+				applied_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+			)`,
+	},
+	// SQLite has no advisory locks. Each migration's transaction locks the
+	// whole database file instead, and a SQLite deployment is one process
+	"sqlite3": {
+		createTable: `
+			CREATE TABLE IF NOT EXISTS schema_migrations (
+				version integer PRIMARY KEY,
+				name text NOT NULL,
+				checksum text NOT NULL,
+				applied_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP
+			)`,
+	},
+}
+
+var (
+	ErrNoChange         = errors.New("no change")
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+	ErrUnknownVersion   = errors.New("unknown migration version")
+	ErrUnknownDriver    = errors.New("unknown database driver")
+)
+
+// Migration files are named NNNNNN_name.up.sql and NNNNNN_name.down.sql
+var filenameRX = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// A Migration is one numbered pair of up and down SQL scripts
+type Migration struct {
+	Version  int64
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+// Status describes a migration and whether it has been applied
+type Status struct {
+	Version   int64      `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+	// Modified is true when the up script changed after it was applied
+	Modified bool `json:"modified,omitempty"`
+}
+
+// Migrator applies migrations read from a file system to a database
+type Migrator struct {
+	db         *sql.DB
+	dialect    dialect
+	migrations []Migration
+	logger     *jsonlog.Logger
+}
+
+// The New() function loads and checks the migrations in fsys. driver is
+// the database/sql driver name, "postgres" or "sqlite3"
+func New(db *sql.DB, driver string, fsys fs.FS, logger *jsonlog.Logger) (*Migrator, error) {
+	d, ok := dialects[driver]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownDriver, driver)
+	}
+	migrations, err := load(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, dialect: d, migrations: migrations, logger: logger}, nil
+}
+
+func load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		match := filenameRX.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(content)
+			sum := sha256.Sum256(content)
+			m.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			m.Down = string(content)
+		}
+	}
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s needs both an up and a down file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// applied is a row of the schema_migrations table
+type applied struct {
+	checksum  string
+	appliedAt time.Time
+}
+
+// withLock() runs fn on a single connection holding the advisory lock, if
+// the database has one, after making sure the schema_migrations table exists
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if m.dialect.lock != "" {
+		_, err = conn.ExecContext(ctx, m.dialect.lock, advisoryLockID)
+		if err != nil {
+			return err
+		}
+		defer conn.ExecContext(context.Background(), m.dialect.unlock, advisoryLockID)
+	}
+
+	_, err = conn.ExecContext(ctx, m.dialect.createTable)
+	if err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+func (m *Migrator) appliedVersions(ctx context.Context, conn *sql.Conn) (map[int64]applied, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT version, checksum, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	versions := make(map[int64]applied)
+	for rows.Next() {
+		var (
+			version int64
+			a       applied
+		)
+		err := rows.Scan(&version, &a.checksum, &a.appliedAt)
+		if err != nil {
+			return nil, err
+		}
+		versions[version] = a
+	}
+	return versions, rows.Err()
+}
+
+// verify() refuses to go on if an applied migration was edited afterwards
+func (m *Migrator) verify(done map[int64]applied) error {
+	for _, mig := range m.migrations {
+		if a, ok := done[mig.Version]; ok && a.checksum != mig.Checksum {
+			return fmt.Errorf("%w: %d_%s was changed after it was applied", ErrChecksumMismatch, mig.Version, mig.Name)
+		}
+	}
+	return nil
+}
+
+// Up() applies every pending migration in order
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.migrateTo(ctx, m.latest())
+}
+
+// Down() rolls back the most recently applied migration
+func (m *Migrator) Down(ctx context.Context) error {
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		done, err := m.appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			if _, ok := done[m.migrations[i].Version]; ok {
+				return m.run(ctx, conn, m.migrations[i], false)
+			}
+		}
+		return ErrNoChange
+	})
+}
+
+// Goto() applies or rolls back migrations until version is the latest one
+// applied. Version 0 rolls everything back
+func (m *Migrator) Goto(ctx context.Context, version int64) error {
+	if version != 0 && m.find(version) == nil {
+		return fmt.Errorf("%w: %d", ErrUnknownVersion, version)
+	}
+	return m.migrateTo(ctx, version)
+}
+
+// Force() records every migration up to version as applied without running
+// it. It is for databases that were migrated by hand before this tool
+func (m *Migrator) Force(ctx context.Context, version int64) error {
+	if m.find(version) == nil {
+		return fmt.Errorf("%w: %d", ErrUnknownVersion, version)
+	}
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		for _, mig := range m.migrations {
+			if mig.Version > version {
+				break
+			}
+			_, err := conn.ExecContext(ctx, `
+				INSERT INTO schema_migrations (version, name, checksum)
+				VALUES ($1, $2, $3)
+				ON CONFLICT (version) DO NOTHING`, mig.Version, mig.Name, mig.Checksum)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Status() lists every known migration and when it was applied
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var statuses []Status
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		done, err := m.appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, mig := range m.migrations {
+			s := Status{Version: mig.Version, Name: mig.Name}
+			if a, ok := done[mig.Version]; ok {
+				appliedAt := a.appliedAt
+				s.AppliedAt = &appliedAt
+				s.Modified = a.checksum != mig.Checksum
+			}
+			statuses = append(statuses, s)
+		}
+		return nil
+	})
+	return statuses, err
+}
+
+func (m *Migrator) migrateTo(ctx context.Context, target int64) error {
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		done, err := m.appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if err := m.verify(done); err != nil {
+			return err
+		}
+		changed := false
+		// Roll back anything above the target, newest first
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			mig := m.migrations[i]
+			if _, ok := done[mig.Version]; ok && mig.Version > target {
+				if err := m.run(ctx, conn, mig, false); err != nil {
+					return err
+				}
+				changed = true
+			}
+		}
+		// Then apply anything missing up to the target, oldest first
+		for _, mig := range m.migrations {
+			if _, ok := done[mig.Version]; !ok && mig.Version <= target {
+				if err := m.run(ctx, conn, mig, true); err != nil {
+					return err
+				}
+				changed = true
+			}
+		}
+		if !changed {
+			return ErrNoChange
+		}
+		return nil
+	})
+}
+
+// run() applies or rolls back one migration in a transaction, so a failed
+// script leaves neither its changes nor its schema_migrations row behind
+func (m *Migrator) run(ctx context.Context, conn *sql.Conn, mig Migration, up bool) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	script, direction := mig.Down, "down"
+	if up {
+		script, direction = mig.Up, "up"
+	}
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return fmt.Errorf("migration %d_%s %s: %w", mig.Version, mig.Name, direction, err)
+	}
+	if up {
+		_, err = tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3)`,
+			mig.Version, mig.Name, mig.Checksum)
+	} else {
+		_, err = tx.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = $1`, mig.Version)
+	}
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	if m.logger != nil {
+		m.logger.PrintInfo("applied migration", map[string]any{
+			"version":   mig.Version,
+			"name":      mig.Name,
+			"direction": direction,
+		})
+	}
+	return nil
+}
+
+func (m *Migrator) latest() int64 {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+func (m *Migrator) find(version int64) *Migration {
+	for i := range m.migrations {
+		if m.migrations[i].Version == version {
+			return &m.migrations[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,2 @@
+-- Filename: migrations/000001_create_todo_list.down.sql
+DROP TABLE IF EXISTS todolist;
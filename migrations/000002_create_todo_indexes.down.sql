@@ -1,3 +1,3 @@
--- Filename: migrations/000003_add_todo_indexes.down.sql
+-- Filename: migrations/000002_create_todo_indexes.down.sql
 DROP INDEX If EXISTS todo_item_idx;
 DROP INDEX If EXISTS todo_description_idx;
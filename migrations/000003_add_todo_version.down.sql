@@ -0,0 +1,2 @@
+-- Filename: migrations/000003_add_todo_version.down.sql
+ALTER TABLE todolist DROP COLUMN IF EXISTS version;
@@ -0,0 +1,2 @@
+-- Filename: migrations/000003_add_todo_version.up.sql
+ALTER TABLE todolist ADD COLUMN IF NOT EXISTS version integer NOT NULL DEFAULT 1;
@@ -0,0 +1,5 @@
+-- Filename: migrations/000004_add_todo_completion.down.sql
+DROP INDEX IF EXISTS todo_completed_idx;
+ALTER TABLE todolist DROP CONSTRAINT IF EXISTS todolist_completed_at_check;
+ALTER TABLE todolist DROP COLUMN IF EXISTS completed_at;
+ALTER TABLE todolist DROP COLUMN IF EXISTS completed;
@@ -0,0 +1,5 @@
+-- Filename: migrations/000004_add_todo_completion.up.sql
+ALTER TABLE todolist ADD COLUMN IF NOT EXISTS completed boolean NOT NULL DEFAULT false;
+ALTER TABLE todolist ADD COLUMN IF NOT EXISTS completed_at timestamp(0) with time zone;
+ALTER TABLE todolist ADD CONSTRAINT todolist_completed_at_check CHECK (completed = (completed_at IS NOT NULL));
+CREATE INDEX IF NOT EXISTS todo_completed_idx ON todolist (completed);
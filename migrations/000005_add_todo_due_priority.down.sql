@@ -0,0 +1,6 @@
+-- Filename: migrations/000005_add_todo_due_priority.down.sql
+DROP INDEX IF EXISTS todo_priority_idx;
+DROP INDEX IF EXISTS todo_due_at_idx;
+ALTER TABLE todolist DROP CONSTRAINT IF EXISTS todolist_priority_check;
+ALTER TABLE todolist DROP COLUMN IF EXISTS priority;
+ALTER TABLE todolist DROP COLUMN IF EXISTS due_at;
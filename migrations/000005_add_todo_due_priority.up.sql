@@ -0,0 +1,6 @@
+-- Filename: migrations/000005_add_todo_due_priority.up.sql
+ALTER TABLE todolist ADD COLUMN IF NOT EXISTS due_at timestamp(0) with time zone;
+ALTER TABLE todolist ADD COLUMN IF NOT EXISTS priority smallint NOT NULL DEFAULT 2;
+ALTER TABLE todolist ADD CONSTRAINT todolist_priority_check CHECK (priority BETWEEN 1 AND 4);
+CREATE INDEX IF NOT EXISTS todo_due_at_idx ON todolist (due_at);
+CREATE INDEX IF NOT EXISTS todo_priority_idx ON todolist (priority);
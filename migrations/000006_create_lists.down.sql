@@ -0,0 +1,4 @@
+-- Filename: migrations/000006_create_lists.down.sql
+DROP INDEX IF EXISTS todo_list_id_idx;
+ALTER TABLE todolist DROP COLUMN IF EXISTS list_id;
+DROP TABLE IF EXISTS lists;
@@ -0,0 +1,21 @@
+-- Filename: migrations/000006_create_lists.up.sql
+CREATE TABLE IF NOT EXISTS lists (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    name text NOT NULL,
+    description text NOT NULL DEFAULT '',
+    is_default boolean NOT NULL DEFAULT false,
+    version integer NOT NULL DEFAULT 1
+);
+
+-- Only one list may be the default list that backs /v1/list
+CREATE UNIQUE INDEX IF NOT EXISTS lists_is_default_idx ON lists (is_default) WHERE is_default;
+
+INSERT INTO lists (name, description, is_default)
+VALUES ('Default', 'Items created through /v1/list', true);
+
+-- Existing items move into the default list. Deleting a list deletes its items
+ALTER TABLE todolist ADD COLUMN IF NOT EXISTS list_id bigint REFERENCES lists ON DELETE CASCADE;
+UPDATE todolist SET list_id = (SELECT id FROM lists WHERE is_default);
+ALTER TABLE todolist ALTER COLUMN list_id SET NOT NULL;
+CREATE INDEX IF NOT EXISTS todo_list_id_idx ON todolist (list_id);
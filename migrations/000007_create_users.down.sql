@@ -0,0 +1,2 @@
+-- Filename: migrations/000007_create_users.down.sql
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,2 @@
+-- Filename: migrations/000008_create_tokens.down.sql
+DROP TABLE IF EXISTS tokens;
@@ -0,0 +1,3 @@
+-- Filename: migrations/000009_add_todo_owner.down.sql
+DROP INDEX IF EXISTS todo_user_id_idx;
+ALTER TABLE todolist DROP COLUMN IF EXISTS user_id;
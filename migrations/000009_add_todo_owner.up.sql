@@ -0,0 +1,4 @@
+-- Filename: migrations/000009_add_todo_owner.up.sql
+-- Items created before users existed have no owner and are not visible to anyone
+ALTER TABLE todolist ADD COLUMN IF NOT EXISTS user_id bigint REFERENCES users ON DELETE CASCADE;
+CREATE INDEX IF NOT EXISTS todo_user_id_idx ON todolist (user_id);
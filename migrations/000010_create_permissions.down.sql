@@ -0,0 +1,3 @@
+-- Filename: migrations/000010_create_permissions.down.sql
+DROP TABLE IF EXISTS users_permissions;
+DROP TABLE IF EXISTS permissions;
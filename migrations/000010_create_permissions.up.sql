@@ -0,0 +1,23 @@
+-- Filename: migrations/000010_create_permissions.up.sql
+CREATE TABLE IF NOT EXISTS permissions (
+    id bigserial PRIMARY KEY,
+    code text NOT NULL UNIQUE
+);
+
+CREATE TABLE IF NOT EXISTS users_permissions (
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    permission_id bigint NOT NULL REFERENCES permissions ON DELETE CASCADE,
+    PRIMARY KEY (user_id, permission_id)
+);
+
+-- todos:admin bypasses per-user ownership and is granted by hand
+INSERT INTO permissions (code)
+VALUES
+    ('todos:read'),
+    ('todos:write'),
+    ('todos:admin');
+
+-- Users who registered before permissions existed keep their access
+INSERT INTO users_permissions
+SELECT users.id, permissions.id FROM users, permissions
+WHERE permissions.code IN ('todos:read', 'todos:write');
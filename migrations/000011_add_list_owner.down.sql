@@ -0,0 +1,3 @@
+-- Filename: migrations/000011_add_list_owner.down.sql
+DROP INDEX IF EXISTS lists_user_id_idx;
+ALTER TABLE lists DROP COLUMN IF EXISTS user_id;
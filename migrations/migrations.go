@@ -0,0 +1,22 @@
+// Filename: migrations/migrations.go
+
+// Package migrations embeds the SQL migration files so that the API binary
+// can apply them without a copy of this directory
+package migrations
+
+import (
+	"embed"
+	"io/fs"
+)
+
+// FS holds every NNNNNN_name.up.sql and NNNNNN_name.down.sql file for
+// PostgreSQL
+//
+//go:embed *.sql
+var FS embed.FS
+
+//go:embed sqlite/*.sql
+var sqliteFiles embed.FS
+
+// SQLite holds the separate migration set for the SQLite backend
+var SQLite, _ = fs.Sub(sqliteFiles, "sqlite")
@@ -0,0 +1,7 @@
+-- Filename: migrations/sqlite/000001_create_schema.down.sql
+DROP TABLE IF EXISTS users_permissions;
+DROP TABLE IF EXISTS permissions;
+DROP TABLE IF EXISTS todolist;
+DROP TABLE IF EXISTS tokens;
+DROP TABLE IF EXISTS users;
+DROP TABLE IF EXISTS lists;
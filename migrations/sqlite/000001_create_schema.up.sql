@@ -0,0 +1,72 @@
+-- Filename: migrations/sqlite/000001_create_schema.up.sql
+-- The SQLite schema matches the PostgreSQL migrations 000001 to 000010.
+-- Timestamps are stored as UTC text, which sorts chronologically
+CREATE TABLE IF NOT EXISTS lists (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    created_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    name text NOT NULL,
+    description text NOT NULL DEFAULT '',
+    is_default boolean NOT NULL DEFAULT false,
+    version integer NOT NULL DEFAULT 1
+);
+
+-- Only one list may be the default list that backs /v1/list
+CREATE UNIQUE INDEX IF NOT EXISTS lists_is_default_idx ON lists (is_default) WHERE is_default;
+
+INSERT INTO lists (name, description, is_default)
+VALUES ('Default', 'Items created through /v1/list', true);
+
+CREATE TABLE IF NOT EXISTS users (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    created_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    name text NOT NULL,
+    email text COLLATE NOCASE UNIQUE NOT NULL,
+    password_hash blob NOT NULL,
+    activated boolean NOT NULL,
+    version integer NOT NULL DEFAULT 1
+);
+
+CREATE TABLE IF NOT EXISTS tokens (
+    hash blob PRIMARY KEY,
+    user_id integer NOT NULL REFERENCES users ON DELETE CASCADE,
+    expiry timestamp NOT NULL,
+    scope text NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS todolist (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    created_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    list_id integer NOT NULL REFERENCES lists ON DELETE CASCADE,
+    user_id integer REFERENCES users ON DELETE CASCADE,
+    item text NOT NULL,
+    description text NOT NULL DEFAULT '',
+    completed boolean NOT NULL DEFAULT false,
+    completed_at timestamp,
+    due_at timestamp,
+    priority smallint NOT NULL DEFAULT 2 CHECK (priority BETWEEN 1 AND 4),
+    version integer NOT NULL DEFAULT 1,
+    CHECK (completed = (completed_at IS NOT NULL))
+);
+CREATE INDEX IF NOT EXISTS todo_completed_idx ON todolist (completed);
+CREATE INDEX IF NOT EXISTS todo_due_at_idx ON todolist (due_at);
+CREATE INDEX IF NOT EXISTS todo_priority_idx ON todolist (priority);
+CREATE INDEX IF NOT EXISTS todo_list_id_idx ON todolist (list_id);
+CREATE INDEX IF NOT EXISTS todo_user_id_idx ON todolist (user_id);
+
+CREATE TABLE IF NOT EXISTS permissions (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    code text NOT NULL UNIQUE
+);
+
+CREATE TABLE IF NOT EXISTS users_permissions (
+    user_id integer NOT NULL REFERENCES users ON DELETE CASCADE,
+    permission_id integer NOT NULL REFERENCES permissions ON DELETE CASCADE,
+    PRIMARY KEY (user_id, permission_id)
+);
+
+-- todos:admin bypasses per-user ownership and is granted by hand
+INSERT INTO permissions (code)
+VALUES
+    ('todos:read'),
+    ('todos:write'),
+    ('todos:admin');
@@ -0,0 +1,9 @@
+-- Filename: migrations/sqlite/000002_create_search_indexes.down.sql
+DROP TRIGGER IF EXISTS lists_fts_update;
+DROP TRIGGER IF EXISTS lists_fts_delete;
+DROP TRIGGER IF EXISTS lists_fts_insert;
+DROP TABLE IF EXISTS lists_fts;
+DROP TRIGGER IF EXISTS todolist_fts_update;
+DROP TRIGGER IF EXISTS todolist_fts_delete;
+DROP TRIGGER IF EXISTS todolist_fts_insert;
+DROP TABLE IF EXISTS todolist_fts;
@@ -0,0 +1,40 @@
+-- Filename: migrations/sqlite/000002_create_search_indexes.up.sql
+-- FTS5 indexes stand in for to_tsvector('simple', ...) in PostgreSQL. They
+-- read their text from the base tables and the triggers keep them in step
+CREATE VIRTUAL TABLE IF NOT EXISTS todolist_fts USING fts5(
+    item, description, content='todolist', content_rowid='id'
+);
+
+CREATE TRIGGER IF NOT EXISTS todolist_fts_insert AFTER INSERT ON todolist BEGIN
+    INSERT INTO todolist_fts (rowid, item, description) VALUES (new.id, new.item, new.description);
+END;
+
+CREATE TRIGGER IF NOT EXISTS todolist_fts_delete AFTER DELETE ON todolist BEGIN
+    INSERT INTO todolist_fts (todolist_fts, rowid, item, description) VALUES ('delete', old.id, old.item, old.description);
+END;
+
+CREATE TRIGGER IF NOT EXISTS todolist_fts_update AFTER UPDATE OF item, description ON todolist BEGIN
+    INSERT INTO todolist_fts (todolist_fts, rowid, item, description) VALUES ('delete', old.id, old.item, old.description);
+    INSERT INTO todolist_fts (rowid, item, description) VALUES (new.id, new.item, new.description);
+END;
+
+CREATE VIRTUAL TABLE IF NOT EXISTS lists_fts USING fts5(
+    name, content='lists', content_rowid='id'
+);
+
+CREATE TRIGGER IF NOT EXISTS lists_fts_insert AFTER INSERT ON lists BEGIN
+    INSERT INTO lists_fts (rowid, name) VALUES (new.id, new.name);
+END;
+
+CREATE TRIGGER IF NOT EXISTS lists_fts_delete AFTER DELETE ON lists BEGIN
+    INSERT INTO lists_fts (lists_fts, rowid, name) VALUES ('delete', old.id, old.name);
+END;
+
+CREATE TRIGGER IF NOT EXISTS lists_fts_update AFTER UPDATE OF name ON lists BEGIN
+    INSERT INTO lists_fts (lists_fts, rowid, name) VALUES ('delete', old.id, old.name);
+    INSERT INTO lists_fts (rowid, name) VALUES (new.id, new.name);
+END;
+
+-- Index whatever was written before the triggers existed
+INSERT INTO todolist_fts (todolist_fts) VALUES ('rebuild');
+INSERT INTO lists_fts (lists_fts) VALUES ('rebuild');
@@ -0,0 +1,3 @@
+-- Filename: migrations/sqlite/000003_add_list_owner.down.sql
+DROP INDEX IF EXISTS lists_user_id_idx;
+ALTER TABLE lists DROP COLUMN user_id;
@@ -0,0 +1,5 @@
+-- Filename: migrations/sqlite/000003_add_list_owner.up.sql
+-- Lists without an owner, like the default list, are shared by every user
+-- and only admins may change them
+ALTER TABLE lists ADD COLUMN user_id integer REFERENCES users ON DELETE CASCADE;
+CREATE INDEX IF NOT EXISTS lists_user_id_idx ON lists (user_id);